@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+)
+
+// AbsenceProof shows a hash isn't a leaf of a sorted tree: it carries the
+// proofs of the two neighbouring leaves whose hashes bracket it. Left is
+// nil when the hash sorts before every leaf, Right when it sorts after
+// every leaf.
+//
+// Absence can only be proven for trees that really are sorted, so the root
+// must come from someone trusted to build the tree in sorted mode.
+type AbsenceProof struct {
+	Hash  []byte
+	Left  *MerkelProof
+	Right *MerkelProof
+}
+
+func rightmostLeaf(node *Node) *Node {
+	for node.right != nil {
+		node = node.right
+	}
+	return node
+}
+
+// neighbours returns the leaves right before and right after where hash
+// would go. Either can be nil at the edges of the tree.
+func (merkelTree *MerkelTree) neighbours(hash []byte) (*Node, *Node) {
+	split := critBit(hash, merkelTree.closestLeaf(hash).hash)
+
+	// Find the subtree the hash would become a sibling of, as insertSorted
+	// does.
+	subtree := merkelTree.root
+	for subtree.left != nil {
+		bit := branchCritBit(subtree)
+		if bit > split {
+			break
+		}
+		if hashBit(hash, bit) == 0 {
+			subtree = subtree.left
+		} else {
+			subtree = subtree.right
+		}
+	}
+
+	if hashBit(hash, split) == 1 {
+		left := rightmostLeaf(subtree)
+		for node := subtree; node.prev != nil; node = node.prev {
+			if node.prev.left == node {
+				return left, leftmostLeaf(node.prev.right)
+			}
+		}
+		return left, nil
+	}
+
+	right := leftmostLeaf(subtree)
+	for node := subtree; node.prev != nil; node = node.prev {
+		if node.prev.right == node {
+			return rightmostLeaf(node.prev.left), right
+		}
+	}
+	return nil, right
+}
+
+// GenerateAbsenceProof proves hash isn't a leaf of the tree. It only works
+// in sorted mode and returns ErrHashExists when the hash is a leaf.
+func (merkelTree *MerkelTree) GenerateAbsenceProof(hash []byte) (*AbsenceProof, error) {
+	if !merkelTree.sorted {
+		return nil, errors.New("absence proofs need a tree in sorted mode")
+	}
+	if merkelTree.root == nil {
+		return nil, errors.New("No root")
+	}
+	// closestLeaf reads bits of hash, so check its length first.
+	if len(hash) != hashSize {
+		return nil, errors.New("hash has the wrong length")
+	}
+	closest := merkelTree.closestLeaf(hash)
+	if compareHash(closest.hash, hash) {
+		return nil, ErrHashExists
+	}
+
+	proof := &AbsenceProof{Hash: hash}
+	left, right := merkelTree.neighbours(hash)
+	var err error
+	if left != nil {
+		if proof.Left, err = merkelTree.GenerateProof(left.hash); err != nil {
+			return nil, err
+		}
+	}
+	if right != nil {
+		if proof.Right, err = merkelTree.GenerateProof(right.hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return proof, nil
+}
+
+// VerifyAbsenceProof checks both neighbour proofs against rootHash, that
+// their leaves bracket the absent hash, and that nothing sits between them.
+func VerifyAbsenceProof(proof *AbsenceProof, rootHash []byte) bool {
+	if proof == nil || (proof.Left == nil && proof.Right == nil) {
+		return false
+	}
+
+	for _, neighbour := range []*MerkelProof{proof.Left, proof.Right} {
+		if neighbour == nil {
+			continue
+		}
+		// Only sorted proofs have fixed size siblings; a concatenated path
+		// can be regrouped into whatever siblings a forger needs.
+		if !neighbour.Sorted || !VerifyProof(neighbour, rootHash) || len(neighbour.ProofList[0]) != len(proof.Hash) {
+			return false
+		}
+	}
+	if proof.Left != nil && bytes.Compare(proof.Left.ProofList[0], proof.Hash) >= 0 {
+		return false
+	}
+	if proof.Right != nil && bytes.Compare(proof.Hash, proof.Right.ProofList[0]) >= 0 {
+		return false
+	}
+
+	switch {
+	case proof.Left == nil:
+		// Leftmost leaf: a left child all the way up.
+		return onEdge(proof.Right.Directions[1:], true)
+	case proof.Right == nil:
+		// Rightmost leaf: a right child all the way up.
+		return onEdge(proof.Left.Directions[1:], false)
+	}
+	return adjacent(proof.Left, proof.Right)
+}
+
+// onEdge reports whether every direction equals side.
+func onEdge(directions []bool, side bool) bool {
+	for _, direction := range directions {
+		if direction != side {
+			return false
+		}
+	}
+	return true
+}
+
+// adjacent reports whether the leaves of two proofs are neighbours: both
+// paths share every node down to the branch where they split, left goes
+// left there and right goes right, and below it left only takes right
+// children while right only takes left children.
+func adjacent(left, right *MerkelProof) bool {
+	l, r := len(left.ProofList)-1, len(right.ProofList)-1
+	for l > 0 && r > 0 {
+		if left.Directions[l] != right.Directions[r] {
+			break
+		}
+		if !compareHash(left.ProofList[l], right.ProofList[r]) {
+			return false
+		}
+		l--
+		r--
+	}
+	if l == 0 || r == 0 || !left.Directions[l] || right.Directions[r] {
+		return false
+	}
+
+	return onEdge(left.Directions[1:l], false) && onEdge(right.Directions[1:r], true)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_AbsenceProof(t *testing.T) {
+	data := []string{}
+	for index := 0; index < 25; index++ {
+		data = append(data, fmt.Sprintf("certificate %d", index))
+	}
+	tree := sortedTreeOf(data)
+	root := tree.rootHash()
+	leaves := tree.Leaves()
+
+	t.Run("Absent hashes", func(t *testing.T) {
+		for index := 0; index < 200; index++ {
+			hash := Hash128([]byte(fmt.Sprintf("revoked %d", index)))
+			proof, err := tree.GenerateAbsenceProof(hash)
+			if err != nil {
+				t.Fatalf("Error: GenerateAbsenceProof: %+v\n", err)
+			}
+			if !VerifyAbsenceProof(proof, root) {
+				t.Errorf("Error: VerifyAbsenceProof: absence of %x rejected\n", hash)
+			}
+		}
+	})
+
+	t.Run("Edges of the tree", func(t *testing.T) {
+		first := leaves[0].hash
+		last := leaves[len(leaves)-1].hash
+		before := bytes.Repeat([]byte{0x00}, len(first))
+		after := bytes.Repeat([]byte{0xff}, len(last))
+
+		proof, _ := tree.GenerateAbsenceProof(before)
+		if proof.Left != nil || !VerifyAbsenceProof(proof, root) {
+			t.Errorf("Error: VerifyAbsenceProof: hash before every leaf rejected")
+		}
+		proof, _ = tree.GenerateAbsenceProof(after)
+		if proof.Right != nil || !VerifyAbsenceProof(proof, root) {
+			t.Errorf("Error: VerifyAbsenceProof: hash after every leaf rejected")
+		}
+
+		single := sortedTreeOf([]string{"only"})
+		proof, err := single.GenerateAbsenceProof(before)
+		if err != nil || !VerifyAbsenceProof(proof, single.rootHash()) {
+			t.Errorf("Error: VerifyAbsenceProof: single leaf tree: %v\n", err)
+		}
+	})
+
+	t.Run("Present hashes and unsorted trees", func(t *testing.T) {
+		if _, err := tree.GenerateAbsenceProof(Hash128([]byte("certificate 3"))); !errors.Is(err, ErrHashExists) {
+			t.Errorf("Error: GenerateAbsenceProof: Expected: %v, Actual: %v\n", ErrHashExists, err)
+		}
+		small := sortedTreeOf([]string{"A", "B", "C"})
+		for _, hash := range [][]byte{nil, {0x01, 0x02}, make([]byte, hashSize+1)} {
+			if _, err := small.GenerateAbsenceProof(hash); err == nil {
+				t.Errorf("Error: GenerateAbsenceProof: %d byte hash accepted\n", len(hash))
+			}
+		}
+		unsorted := InitMerkelTree()
+		unsorted.Insert([]byte("A"))
+		if _, err := unsorted.GenerateAbsenceProof(Hash128([]byte("B"))); err == nil {
+			t.Errorf("Error: GenerateAbsenceProof: unsorted tree accepted")
+		}
+	})
+
+	t.Run("Forged proofs", func(t *testing.T) {
+		// Claim leaf 5 is absent using the neighbours of the gap before it.
+		present := leaves[5].hash
+		proof, _ := tree.GenerateAbsenceProof(Hash128([]byte("revoked 0")))
+		forged := &AbsenceProof{Hash: present, Left: proof.Left, Right: proof.Right}
+		if VerifyAbsenceProof(forged, root) {
+			t.Errorf("Error: VerifyAbsenceProof: hash outside the bracket accepted")
+		}
+
+		// Neighbours that aren't adjacent, skipping over leaf 5.
+		left, _ := tree.GenerateProof(leaves[4].hash)
+		right, _ := tree.GenerateProof(leaves[6].hash)
+		forged = &AbsenceProof{Hash: present, Left: left, Right: right}
+		if VerifyAbsenceProof(forged, root) {
+			t.Errorf("Error: VerifyAbsenceProof: non adjacent leaves accepted")
+		}
+
+		// Only one neighbour, pretending it's the edge of the tree.
+		forged = &AbsenceProof{Hash: present, Left: left}
+		if VerifyAbsenceProof(forged, root) {
+			t.Errorf("Error: VerifyAbsenceProof: fake right edge accepted")
+		}
+		if VerifyAbsenceProof(&AbsenceProof{Hash: present}, root) {
+			t.Errorf("Error: VerifyAbsenceProof: proof without neighbours accepted")
+		}
+
+		// A window over the concatenated path: the whole root passed off as
+		// the rightmost leaf.
+		after := bytes.Repeat([]byte{0xff}, len(root))
+		for _, sorted := range []bool{false, true} {
+			window := &MerkelProof{LeafHash: root, ProofList: [][]byte{root}, Directions: []bool{true}, Sorted: sorted}
+			if VerifyAbsenceProof(&AbsenceProof{Hash: after, Left: window}, root) {
+				t.Errorf("Error: VerifyAbsenceProof: root used as a leaf accepted (sorted %v)\n", sorted)
+			}
+		}
+
+		// Siblings regrouped into a differently sized window.
+		regrouped, _ := tree.GenerateProof(leaves[4].hash)
+		regrouped.ProofList = append([][]byte{regrouped.ProofList[0], append(append([]byte{}, regrouped.ProofList[1]...), regrouped.ProofList[2]...)}, regrouped.ProofList[3:]...)
+		regrouped.Directions = append([]bool{true}, regrouped.Directions[2:]...)
+		if VerifyProof(regrouped, root) {
+			t.Errorf("Error: VerifyProof: regrouped siblings accepted")
+		}
+		proof, _ = tree.GenerateAbsenceProof(Hash128([]byte("revoked 1")))
+		unsorted := *proof.Left
+		unsorted.Sorted = false
+		if VerifyAbsenceProof(&AbsenceProof{Hash: proof.Hash, Left: &unsorted, Right: proof.Right}, root) {
+			t.Errorf("Error: VerifyAbsenceProof: unsorted neighbour proof accepted")
+		}
+	})
+}
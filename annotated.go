@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Aggregate describes an annotation carried by every node of an annotated
+// MerkelTree. Annotations are byte strings in whatever encoding the caller
+// picks: leaves get theirs from InsertAnnotated, branches get Combine of
+// their children's, and every hash commits to the annotations below it.
+// Combine should be associative (a monoid) so the root annotation doesn't
+// depend on where leaves ended up: a sum, a count, a min/max timestamp or
+// OR-ed bloom bits.
+type Aggregate struct {
+	// Combine returns the annotation of a branch from its children's. An
+	// error, e.g. an overflow, rejects the Insert or Update that led to it.
+	// Delete doesn't check it, so dropping a leaf must never make it fail.
+	Combine func(left, right []byte) ([]byte, error)
+}
+
+// errNotAnnotated is returned when annotations are used on a plain tree or
+// left out on an annotated one.
+var errNotAnnotated = errors.New("annotations go with annotated trees only, see InitAnnotatedMerkelTree")
+
+// InitAnnotatedMerkelTree initializes a MerkelTree whose nodes carry
+// annotations maintained by aggregate. Leaves are placed like Insert does,
+// and Insert, Update and Delete recompute the annotations and hashes along
+// the prev path only. Annotated hashes commit to the annotations:
+//
+//	leaf = Hash128(0x00 || Hash128(data) || annotation)
+//	node = Hash128(0x01 || left || len(left annotation) || left annotation || right || right annotation)
+//
+// The length is a uvarint. Annotated trees are proven with
+// GenerateAnnotatedProof rather than GenerateProof and can't be saved.
+func InitAnnotatedMerkelTree(aggregate Aggregate) *MerkelTree {
+	merkelTree := InitMerkelTree()
+	merkelTree.aggregate = &aggregate
+	return merkelTree
+}
+
+// annotatedLeafHash commits to a leaf hash and its annotation.
+func annotatedLeafHash(hash, annotation []byte) []byte {
+	return Hash128(append(append([]byte{0x00}, hash...), annotation...))
+}
+
+func annotatedNodeHash(left, leftAnnotation, right, rightAnnotation []byte) []byte {
+	joined := append([]byte{0x01}, left...)
+	joined = binary.AppendUvarint(joined, uint64(len(leftAnnotation)))
+	joined = append(joined, leftAnnotation...)
+	joined = append(joined, right...)
+	joined = append(joined, rightAnnotation...)
+	return Hash128(joined)
+}
+
+// InsertAnnotated adds data with its annotation and returns the leaf hash,
+// Hash128(data). If combining annotations fails the tree is left as it was.
+func (merkelTree *MerkelTree) InsertAnnotated(data, annotation []byte) ([]byte, error) {
+	if merkelTree.aggregate == nil {
+		return nil, errNotAnnotated
+	}
+	return merkelTree.insert(data, annotation)
+}
+
+// UpdateAnnotation replaces the annotation of the leaf with hash leafHash
+// (current or historical). If combining annotations fails the tree is left
+// as it was.
+func (merkelTree *MerkelTree) UpdateAnnotation(leafHash, annotation []byte) error {
+	if merkelTree.aggregate == nil {
+		return errNotAnnotated
+	}
+	leaf, err := merkelTree.Lookup(leafHash)
+	if err != nil {
+		return ErrHashNotFound
+	}
+
+	oldRoot := merkelTree.rootHash()
+	previous := leaf.annotation
+	leaf.annotation = annotation
+	if err := merkelTree.rehash(leaf.prev); err != nil {
+		leaf.annotation = previous
+		merkelTree.rehash(leaf.prev)
+		return err
+	}
+
+	merkelTree.emit(TreeEvent{
+		Kind:    EventUpdate,
+		OldHash: leaf.hash,
+		NewHash: leaf.hash,
+		OldRoot: oldRoot,
+		NewRoot: merkelTree.rootHash(),
+	})
+	return nil
+}
+
+// Annotation returns the annotation of the leaf with hash leafHash.
+func (merkelTree *MerkelTree) Annotation(leafHash []byte) ([]byte, error) {
+	leaf, err := merkelTree.Lookup(leafHash)
+	if err != nil {
+		return nil, ErrHashNotFound
+	}
+	return leaf.annotation, nil
+}
+
+// RootAnnotation returns the annotation of the whole tree, nil for an empty
+// tree.
+func (merkelTree *MerkelTree) RootAnnotation() []byte {
+	if merkelTree.root == nil {
+		return nil
+	}
+	return merkelTree.root.annotation
+}
+
+// AnnotatedProofStep is a sibling on the way from a leaf to the root.
+type AnnotatedProofStep struct {
+	Hash       []byte
+	Annotation []byte
+	// Left is true when the sibling is the left child.
+	Left bool
+}
+
+// AnnotatedProof proves a leaf and its annotation are part of an annotated
+// MerkelTree.
+type AnnotatedProof struct {
+	LeafHash   []byte
+	Annotation []byte
+	ProofList  []AnnotatedProofStep
+}
+
+// GenerateAnnotatedProof returns the proof for the leaf with hash leafHash
+// in an annotated tree.
+func (merkelTree *MerkelTree) GenerateAnnotatedProof(leafHash []byte) (*AnnotatedProof, error) {
+	if merkelTree.aggregate == nil {
+		return nil, errNotAnnotated
+	}
+	leaf, err := merkelTree.Lookup(leafHash)
+	if err != nil {
+		return nil, ErrHashNotFound
+	}
+
+	proof := &AnnotatedProof{LeafHash: leaf.hash, Annotation: leaf.annotation, ProofList: []AnnotatedProofStep{}}
+	for node := leaf; node.prev != nil; node = node.prev {
+		sibling, left := node.prev.left, true
+		if sibling == node {
+			sibling, left = node.prev.right, false
+		}
+		proof.ProofList = append(proof.ProofList, AnnotatedProofStep{
+			Hash:       merkelTree.commitment(sibling),
+			Annotation: sibling.annotation,
+			Left:       left,
+		})
+	}
+	return proof, nil
+}
+
+// VerifyAnnotatedProof checks that the proof rebuilds rootHash and that the
+// annotations combine up to rootAnnotation.
+func VerifyAnnotatedProof(aggregate Aggregate, proof *AnnotatedProof, rootHash, rootAnnotation []byte) bool {
+	if proof == nil {
+		return false
+	}
+
+	annotation := proof.Annotation
+	hash := annotatedLeafHash(proof.LeafHash, annotation)
+	for _, step := range proof.ProofList {
+		var err error
+		if step.Left {
+			hash = annotatedNodeHash(step.Hash, step.Annotation, hash, annotation)
+			annotation, err = aggregate.Combine(step.Annotation, annotation)
+		} else {
+			hash = annotatedNodeHash(hash, annotation, step.Hash, step.Annotation)
+			annotation, err = aggregate.Combine(annotation, step.Annotation)
+		}
+		if err != nil {
+			return false
+		}
+	}
+
+	return bytes.Equal(annotation, rootAnnotation) && compareHash(hash, rootHash)
+}
+
+// SumAggregate sums amounts encoded as big endian uint64s and rejects
+// totals that overflow. It's what SumTree is built on.
+var SumAggregate = Aggregate{
+	Combine: func(left, right []byte) ([]byte, error) {
+		if len(left) != 8 || len(right) != 8 {
+			return nil, errors.New("amounts must be 8 byte big endian integers")
+		}
+		a, b := binary.BigEndian.Uint64(left), binary.BigEndian.Uint64(right)
+		if b > math.MaxUint64-a {
+			return nil, errors.New("total overflows")
+		}
+		return binary.BigEndian.AppendUint64(nil, a+b), nil
+	},
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// timeRange is a test annotation: how many leaves there are and the
+// earliest and latest timestamp among them.
+type timeRange struct {
+	Count    uint64
+	Earliest int64
+	Latest   int64
+}
+
+func (annotation timeRange) encode() []byte {
+	encoded := binary.BigEndian.AppendUint64(nil, annotation.Count)
+	encoded = binary.BigEndian.AppendUint64(encoded, uint64(annotation.Earliest))
+	return binary.BigEndian.AppendUint64(encoded, uint64(annotation.Latest))
+}
+
+func decodeTimeRange(encoded []byte) timeRange {
+	return timeRange{
+		Count:    binary.BigEndian.Uint64(encoded),
+		Earliest: int64(binary.BigEndian.Uint64(encoded[8:])),
+		Latest:   int64(binary.BigEndian.Uint64(encoded[16:])),
+	}
+}
+
+var timeRangeAggregate = Aggregate{
+	Combine: func(left, right []byte) ([]byte, error) {
+		if len(left) != 24 || len(right) != 24 {
+			return nil, errors.New("time ranges are 24 bytes")
+		}
+		l, r := decodeTimeRange(left), decodeTimeRange(right)
+		return timeRange{
+			Count:    l.Count + r.Count,
+			Earliest: min(l.Earliest, r.Earliest),
+			Latest:   max(l.Latest, r.Latest),
+		}.encode(), nil
+	},
+}
+
+func Test_AnnotatedTree(t *testing.T) {
+	tree := InitAnnotatedMerkelTree(timeRangeAggregate)
+	timestamps := []int64{500, 120, 900, 42, 77, 613, 300}
+	for index, timestamp := range timestamps {
+		data := []byte(fmt.Sprintf("event %d", index))
+		if _, err := tree.InsertAnnotated(data, timeRange{Count: 1, Earliest: timestamp, Latest: timestamp}.encode()); err != nil {
+			t.Fatalf("Error: Insert: %+v\n", err)
+		}
+	}
+
+	t.Run("Root annotation", func(t *testing.T) {
+		root := decodeTimeRange(tree.RootAnnotation())
+		expected := timeRange{Count: 7, Earliest: 42, Latest: 900}
+		if root != expected {
+			t.Errorf("Error: RootAnnotation: Expected: %+v, Actual: %+v\n", expected, root)
+		}
+		if InitAnnotatedMerkelTree(timeRangeAggregate).RootAnnotation() != nil {
+			t.Errorf("Error: RootAnnotation: empty tree has an annotation")
+		}
+	})
+
+	t.Run("Proofs verify with the root annotation", func(t *testing.T) {
+		root := tree.RootAnnotation()
+		for index := range timestamps {
+			proof, err := tree.GenerateAnnotatedProof(Hash128([]byte(fmt.Sprintf("event %d", index))))
+			if err != nil {
+				t.Fatalf("Error: GenerateAnnotatedProof: %+v\n", err)
+			}
+			if !VerifyAnnotatedProof(timeRangeAggregate, proof, tree.rootHash(), root) {
+				t.Errorf("Error: VerifyAnnotatedProof: event %d rejected\n", index)
+			}
+			wrong := decodeTimeRange(root)
+			wrong.Latest++
+			if VerifyAnnotatedProof(timeRangeAggregate, proof, tree.rootHash(), wrong.encode()) {
+				t.Errorf("Error: VerifyAnnotatedProof: wrong root annotation accepted")
+			}
+		}
+	})
+
+	t.Run("Update keeps the aggregates", func(t *testing.T) {
+		hash := Hash128([]byte("event 2"))
+		if err := tree.UpdateAnnotation(hash, timeRange{Count: 1, Earliest: 10, Latest: 10}.encode()); err != nil {
+			t.Fatalf("Error: UpdateAnnotation: %+v\n", err)
+		}
+		root := tree.RootAnnotation()
+		if decodeTimeRange(root) != (timeRange{Count: 7, Earliest: 10, Latest: 613}) {
+			t.Errorf("Error: UpdateAnnotation: unexpected root annotation %+v\n", decodeTimeRange(root))
+		}
+		annotation, _ := tree.Annotation(hash)
+		if decodeTimeRange(annotation).Earliest != 10 {
+			t.Errorf("Error: Annotation: Expected: 10, Actual: %d\n", decodeTimeRange(annotation).Earliest)
+		}
+		proof, _ := tree.GenerateAnnotatedProof(Hash128([]byte("event 5")))
+		if !VerifyAnnotatedProof(timeRangeAggregate, proof, tree.rootHash(), root) {
+			t.Errorf("Error: VerifyAnnotatedProof: proof after update rejected")
+		}
+	})
+
+	t.Run("Delete keeps the aggregates", func(t *testing.T) {
+		if err := tree.Delete(Hash128([]byte("event 2"))); err != nil {
+			t.Fatalf("Error: Delete: %+v\n", err)
+		}
+		root := tree.RootAnnotation()
+		if decodeTimeRange(root) != (timeRange{Count: 6, Earliest: 42, Latest: 613}) {
+			t.Errorf("Error: Delete: unexpected root annotation %+v\n", decodeTimeRange(root))
+		}
+		proof, _ := tree.GenerateAnnotatedProof(Hash128([]byte("event 0")))
+		if !VerifyAnnotatedProof(timeRangeAggregate, proof, tree.rootHash(), root) {
+			t.Errorf("Error: VerifyAnnotatedProof: proof after delete rejected")
+		}
+	})
+
+	t.Run("Plain trees aren't annotated", func(t *testing.T) {
+		plain := InitMerkelTree()
+		plain.Insert([]byte("A"))
+		if _, err := plain.InsertAnnotated([]byte("B"), nil); err == nil {
+			t.Errorf("Error: InsertAnnotated: plain tree accepted an annotation")
+		}
+		if _, err := plain.GenerateAnnotatedProof(Hash128([]byte("A"))); err == nil {
+			t.Errorf("Error: GenerateAnnotatedProof: plain tree accepted")
+		}
+		if _, err := tree.GenerateProof(Hash128([]byte("event 0"))); err == nil {
+			t.Errorf("Error: GenerateProof: annotated tree accepted")
+		}
+	})
+
+	t.Run("Leaves need annotations", func(t *testing.T) {
+		empty := InitAnnotatedMerkelTree(SumAggregate)
+		if _, err := empty.Insert([]byte("A")); !errors.Is(err, errNotAnnotated) {
+			t.Errorf("Error: Insert: empty tree. Expected: %v, Actual: %v\n", errNotAnnotated, err)
+		}
+		if _, err := empty.InsertAnnotated([]byte("A"), nil); !errors.Is(err, errNotAnnotated) {
+			t.Errorf("Error: InsertAnnotated: nil annotation. Expected: %v, Actual: %v\n", errNotAnnotated, err)
+		}
+		if _, err := tree.Insert([]byte("plain")); !errors.Is(err, errNotAnnotated) {
+			t.Errorf("Error: Insert: Expected: %v, Actual: %v\n", errNotAnnotated, err)
+		}
+		if _, err := empty.InsertAnnotated([]byte("B"), binary.BigEndian.AppendUint64(nil, 1)); err != nil {
+			t.Errorf("Error: InsertAnnotated: after rejected inserts: %v\n", err)
+		}
+	})
+
+	t.Run("Explorer and renderers", func(t *testing.T) {
+		if err := tree.ExportHTML(io.Discard); err != nil {
+			t.Errorf("Error: ExportHTML: %v\n", err)
+		}
+		proof := &MerkelProof{LeafHash: Hash128([]byte("event 0"))}
+		if err := tree.Render(io.Discard, RenderOptions{Format: RenderDOT, Proof: proof}); err != nil {
+			t.Errorf("Error: Render: %v\n", err)
+		}
+	})
+
+	t.Run("Failing aggregate leaves the tree unchanged", func(t *testing.T) {
+		errTooMany := errors.New("too many leaves")
+		capped := Aggregate{
+			Combine: func(left, right []byte) ([]byte, error) {
+				sum, err := SumAggregate.Combine(left, right)
+				if err == nil && binary.BigEndian.Uint64(sum) > 3 {
+					return nil, errTooMany
+				}
+				return sum, err
+			},
+		}
+		one := binary.BigEndian.AppendUint64(nil, 1)
+		tree := InitAnnotatedMerkelTree(capped)
+		tree.InsertAnnotated([]byte("A"), one)
+		tree.InsertAnnotated([]byte("B"), one)
+		tree.InsertAnnotated([]byte("C"), one)
+		root := tree.rootHash()
+
+		if _, err := tree.InsertAnnotated([]byte("D"), one); !errors.Is(err, errTooMany) {
+			t.Errorf("Error: InsertAnnotated: Expected: %v, Actual: %v\n", errTooMany, err)
+		}
+		if err := tree.UpdateAnnotation(Hash128([]byte("A")), binary.BigEndian.AppendUint64(nil, 2)); !errors.Is(err, errTooMany) {
+			t.Errorf("Error: UpdateAnnotation: Expected: %v, Actual: %v\n", errTooMany, err)
+		}
+		if !compareHash(root, tree.rootHash()) {
+			t.Errorf("Error: Insert: rejected changes altered the root")
+		}
+		if _, err := tree.GenerateAnnotatedProof(Hash128([]byte("D"))); !errors.Is(err, ErrHashNotFound) {
+			t.Errorf("Error: GenerateAnnotatedProof: rejected leaf is in the tree")
+		}
+		for _, data := range []string{"A", "B", "C"} {
+			proof, _ := tree.GenerateAnnotatedProof(Hash128([]byte(data)))
+			if !VerifyAnnotatedProof(capped, proof, root, binary.BigEndian.AppendUint64(nil, 3)) {
+				t.Errorf("Error: VerifyAnnotatedProof: %s rejected after a failed change\n", data)
+			}
+		}
+	})
+}
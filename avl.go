@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+)
+
+// AVLTree is an authenticated, ordered key-value store in the style of
+// Cosmos IAVL: a self-balancing AVL+ tree whose values live in the leaves,
+// with versioned commits. Nodes are never modified once built; every change
+// copies the path it touches, so every saved version keeps its own root
+// and shares all untouched nodes with the others.
+//
+// Inner nodes carry the smallest key of their right subtree for searching,
+// but their hash only commits to their shape and children:
+//
+//	leaf  = Hash128(0x00 || len(key) || key || Hash128(value))
+//	inner = Hash128(0x01 || height || size || left || right)
+//
+// height is a single byte and size a big endian uint64.
+type AVLTree struct {
+	// working is the tree Set and Remove change; SaveVersion commits it.
+	working  *avlNode
+	versions map[int64]*avlNode
+	latest   int64
+}
+
+type avlNode struct {
+	key    []byte
+	value  []byte
+	height int8
+	size   int64
+	left   *avlNode
+	right  *avlNode
+	hash   []byte
+}
+
+// NewAVLTree creates an empty tree without any saved version.
+func NewAVLTree() *AVLTree {
+	return &AVLTree{versions: map[int64]*avlNode{}}
+}
+
+func newAVLLeaf(key, value []byte) *avlNode {
+	node := &avlNode{key: key, value: value, size: 1}
+	encoded := binary.AppendUvarint([]byte{0x00}, uint64(len(key)))
+	encoded = append(encoded, key...)
+	node.hash = Hash128(append(encoded, Hash128(value)...))
+	return node
+}
+
+func newAVLInner(left, right *avlNode) *avlNode {
+	node := &avlNode{
+		key:    avlLeftmost(right).key,
+		height: max(left.height, right.height) + 1,
+		size:   left.size + right.size,
+		left:   left,
+		right:  right,
+	}
+	node.hash = avlInnerHash(node.height, node.size, left.hash, right.hash)
+	return node
+}
+
+func avlInnerHash(height int8, size int64, left, right []byte) []byte {
+	encoded := binary.BigEndian.AppendUint64([]byte{0x01, byte(height)}, uint64(size))
+	encoded = append(encoded, left...)
+	return Hash128(append(encoded, right...))
+}
+
+func avlLeftmost(node *avlNode) *avlNode {
+	for node.left != nil {
+		node = node.left
+	}
+	return node
+}
+
+func avlRightmost(node *avlNode) *avlNode {
+	for node.right != nil {
+		node = node.right
+	}
+	return node
+}
+
+func (node *avlNode) isLeaf() bool {
+	return node.left == nil
+}
+
+// avlRootHash is the root hash of a tree; an empty tree hashes to
+// Hash128(nil).
+func avlRootHash(root *avlNode) []byte {
+	if root == nil {
+		return Hash128(nil)
+	}
+	return root.hash
+}
+
+// RootHash returns the root hash of the working tree.
+func (tree *AVLTree) RootHash() []byte {
+	return avlRootHash(tree.working)
+}
+
+// Len returns the number of keys in the working tree.
+func (tree *AVLTree) Len() int64 {
+	if tree.working == nil {
+		return 0
+	}
+	return tree.working.size
+}
+
+func avlGet(node *avlNode, key []byte) ([]byte, error) {
+	for node != nil && !node.isLeaf() {
+		if bytes.Compare(key, node.key) < 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+	if node == nil || !bytes.Equal(node.key, key) {
+		return nil, fmt.Errorf("%w: %x", ErrKeyNotFound, key)
+	}
+	return append([]byte{}, node.value...), nil
+}
+
+// Get returns the value of key in the working tree.
+func (tree *AVLTree) Get(key []byte) ([]byte, error) {
+	return avlGet(tree.working, key)
+}
+
+// GetVersioned returns the value key had in a saved version.
+func (tree *AVLTree) GetVersioned(key []byte, version int64) ([]byte, error) {
+	root, err := tree.versionRoot(version)
+	if err != nil {
+		return nil, err
+	}
+	return avlGet(root, key)
+}
+
+// Set stores value under key in the working tree and reports whether an
+// existing key was updated.
+func (tree *AVLTree) Set(key, value []byte) bool {
+	var updated bool
+	tree.working, updated = avlSet(tree.working, append([]byte{}, key...), append([]byte{}, value...))
+	return updated
+}
+
+func avlSet(node *avlNode, key, value []byte) (*avlNode, bool) {
+	if node == nil {
+		return newAVLLeaf(key, value), false
+	}
+
+	if node.isLeaf() {
+		switch bytes.Compare(key, node.key) {
+		case 0:
+			return newAVLLeaf(key, value), true
+		case -1:
+			return newAVLInner(newAVLLeaf(key, value), node), false
+		default:
+			return newAVLInner(node, newAVLLeaf(key, value)), false
+		}
+	}
+
+	var updated bool
+	left, right := node.left, node.right
+	if bytes.Compare(key, node.key) < 0 {
+		left, updated = avlSet(left, key, value)
+	} else {
+		right, updated = avlSet(right, key, value)
+	}
+	return avlBalance(left, right), updated
+}
+
+// Remove deletes key from the working tree.
+func (tree *AVLTree) Remove(key []byte) error {
+	root, removed := avlRemove(tree.working, key)
+	if !removed {
+		return fmt.Errorf("%w: %x", ErrKeyNotFound, key)
+	}
+	tree.working = root
+	return nil
+}
+
+func avlRemove(node *avlNode, key []byte) (*avlNode, bool) {
+	if node == nil {
+		return nil, false
+	}
+	if node.isLeaf() {
+		if !bytes.Equal(node.key, key) {
+			return node, false
+		}
+		return nil, true
+	}
+
+	if bytes.Compare(key, node.key) < 0 {
+		left, removed := avlRemove(node.left, key)
+		if !removed {
+			return node, false
+		}
+		if left == nil {
+			return node.right, true
+		}
+		return avlBalance(left, node.right), true
+	}
+
+	right, removed := avlRemove(node.right, key)
+	if !removed {
+		return node, false
+	}
+	if right == nil {
+		return node.left, true
+	}
+	return avlBalance(node.left, right), true
+}
+
+// avlBalance builds the inner node over left and right, rotating when
+// their heights differ by more than one.
+func avlBalance(left, right *avlNode) *avlNode {
+	switch {
+	case left.height > right.height+1:
+		if left.left.height < left.right.height {
+			// Left-right case: rotate the left child left first.
+			left = newAVLInner(newAVLInner(left.left, left.right.left), left.right.right)
+		}
+		return newAVLInner(left.left, newAVLInner(left.right, right))
+	case right.height > left.height+1:
+		if right.right.height < right.left.height {
+			right = newAVLInner(right.left.left, newAVLInner(right.left.right, right.right))
+		}
+		return newAVLInner(newAVLInner(left, right.left), right.right)
+	}
+	return newAVLInner(left, right)
+}
+
+// Iterate returns the keys in [start, end) of the working tree in order,
+// with their values. A nil start or end leaves that side unbounded.
+//
+//	for key, value := range tree.Iterate([]byte("a"), []byte("b")) {
+//		...
+//	}
+func (tree *AVLTree) Iterate(start, end []byte) iter.Seq2[[]byte, []byte] {
+	root := tree.working
+	return func(yield func([]byte, []byte) bool) {
+		avlIterate(root, start, end, yield)
+	}
+}
+
+func avlIterate(node *avlNode, start, end []byte, yield func([]byte, []byte) bool) bool {
+	if node == nil {
+		return true
+	}
+	if node.isLeaf() {
+		if (start != nil && bytes.Compare(node.key, start) < 0) || (end != nil && bytes.Compare(node.key, end) >= 0) {
+			return true
+		}
+		// Nodes are shared between versions, so callers get copies.
+		return yield(append([]byte{}, node.key...), append([]byte{}, node.value...))
+	}
+
+	if start == nil || bytes.Compare(start, node.key) < 0 {
+		if !avlIterate(node.left, start, end, yield) {
+			return false
+		}
+	}
+	if end == nil || bytes.Compare(end, node.key) > 0 {
+		return avlIterate(node.right, start, end, yield)
+	}
+	return true
+}
+
+// SaveVersion commits the working tree as the next version and returns
+// its root hash and version number. Versions count up from 1 and never
+// repeat, even after LoadVersion went back to an older one.
+func (tree *AVLTree) SaveVersion() ([]byte, int64) {
+	tree.latest++
+	tree.versions[tree.latest] = tree.working
+	return avlRootHash(tree.working), tree.latest
+}
+
+// LoadVersion replaces the working tree with a saved version, dropping any
+// unsaved changes.
+func (tree *AVLTree) LoadVersion(version int64) error {
+	root, err := tree.versionRoot(version)
+	if err != nil {
+		return err
+	}
+	tree.working = root
+	return nil
+}
+
+// DeleteVersion forgets a saved version. Nodes it shares with other
+// versions or the working tree stay alive.
+func (tree *AVLTree) DeleteVersion(version int64) error {
+	if _, err := tree.versionRoot(version); err != nil {
+		return err
+	}
+	delete(tree.versions, version)
+	return nil
+}
+
+// Versions returns the saved versions in ascending order.
+func (tree *AVLTree) Versions() []int64 {
+	versions := []int64{}
+	for version := range tree.versions {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// VersionRootHash returns the root hash of a saved version.
+func (tree *AVLTree) VersionRootHash(version int64) ([]byte, error) {
+	root, err := tree.versionRoot(version)
+	if err != nil {
+		return nil, err
+	}
+	return avlRootHash(root), nil
+}
+
+func (tree *AVLTree) versionRoot(version int64) (*avlNode, error) {
+	root, ok := tree.versions[version]
+	if !ok {
+		return nil, fmt.Errorf("version %d doesn't exist", version)
+	}
+	return root, nil
+}
+
+// AVLProofStep is an inner node on the way from a leaf to the root.
+type AVLProofStep struct {
+	Height  int8
+	Size    int64
+	Sibling []byte
+	// Left is true when the sibling is the left child.
+	Left bool
+}
+
+// AVLLeafProof proves a key and its value are a leaf of the tree.
+type AVLLeafProof struct {
+	Key   []byte
+	Value []byte
+	// Path runs from the leaf up to the root.
+	Path []AVLProofStep
+}
+
+// AVLProof proves a key's value or its absence. Leaf is set when the key
+// exists. Otherwise Left and Right are the neighbouring leaves whose keys
+// bracket it; either is nil at the edges of the tree, and both are nil for
+// an empty tree.
+type AVLProof struct {
+	Key   []byte
+	Leaf  *AVLLeafProof
+	Left  *AVLLeafProof
+	Right *AVLLeafProof
+}
+
+// Prove returns the existence or absence proof of key in the working tree.
+func (tree *AVLTree) Prove(key []byte) *AVLProof {
+	return avlProve(tree.working, key)
+}
+
+// ProveVersion returns the existence or absence proof of key in a saved
+// version.
+func (tree *AVLTree) ProveVersion(key []byte, version int64) (*AVLProof, error) {
+	root, err := tree.versionRoot(version)
+	if err != nil {
+		return nil, err
+	}
+	return avlProve(root, key), nil
+}
+
+// avlLeafProof walks to the leaf at position index and records the path.
+func avlLeafProof(root *avlNode, index int64) *AVLLeafProof {
+	steps := []AVLProofStep{}
+	node := root
+	for !node.isLeaf() {
+		step := AVLProofStep{Height: node.height, Size: node.size}
+		if index < node.left.size {
+			step.Sibling, step.Left = append([]byte{}, node.right.hash...), false
+			node = node.left
+		} else {
+			step.Sibling, step.Left = append([]byte{}, node.left.hash...), true
+			index -= node.left.size
+			node = node.right
+		}
+		steps = append(steps, step)
+	}
+
+	// Collected top down; proofs run bottom up.
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return &AVLLeafProof{Key: append([]byte{}, node.key...), Value: append([]byte{}, node.value...), Path: steps}
+}
+
+func avlProve(root *avlNode, key []byte) *AVLProof {
+	proof := &AVLProof{Key: key}
+	if root == nil {
+		return proof
+	}
+
+	// index is the number of keys smaller than key.
+	index := int64(0)
+	node := root
+	for !node.isLeaf() {
+		if bytes.Compare(key, node.key) < 0 {
+			node = node.left
+		} else {
+			index += node.left.size
+			node = node.right
+		}
+	}
+	switch bytes.Compare(key, node.key) {
+	case 0:
+		proof.Leaf = avlLeafProof(root, index)
+		return proof
+	case 1:
+		index++
+	}
+
+	if index > 0 {
+		proof.Left = avlLeafProof(root, index-1)
+	}
+	if index < root.size {
+		proof.Right = avlLeafProof(root, index)
+	}
+	return proof
+}
+
+// rebuild hashes the leaf up to the root.
+func (leaf *AVLLeafProof) rebuild() []byte {
+	hash := newAVLLeaf(leaf.Key, leaf.Value).hash
+	for _, step := range leaf.Path {
+		if step.Left {
+			hash = avlInnerHash(step.Height, step.Size, step.Sibling, hash)
+		} else {
+			hash = avlInnerHash(step.Height, step.Size, hash, step.Sibling)
+		}
+	}
+	return hash
+}
+
+// avlOnEdge reports whether every sibling on the path is on side, i.e. the
+// leaf is the rightmost (siblings all left) or leftmost one.
+func avlOnEdge(path []AVLProofStep, left bool) bool {
+	for _, step := range path {
+		if step.Left != left {
+			return false
+		}
+	}
+	return true
+}
+
+// avlAdjacent reports whether two leaves are neighbours: their paths share
+// every node down to the one where they split, and below it the left leaf
+// is always a right child and the right leaf always a left child.
+func avlAdjacent(left, right *AVLLeafProof) bool {
+	l, r := len(left.Path)-1, len(right.Path)-1
+	for l >= 0 && r >= 0 {
+		a, b := left.Path[l], right.Path[r]
+		if a.Height != b.Height || a.Size != b.Size {
+			return false
+		}
+		if a.Left != b.Left {
+			break
+		}
+		if !compareHash(a.Sibling, b.Sibling) {
+			return false
+		}
+		l--
+		r--
+	}
+	if l < 0 || r < 0 || left.Path[l].Left || !right.Path[r].Left {
+		return false
+	}
+
+	return avlOnEdge(left.Path[:l], true) && avlOnEdge(right.Path[:r], false)
+}
+
+// VerifyAVLProof checks a proof from Prove or ProveVersion against a
+// trusted root. It returns the value of key, or found == false when the
+// proof shows the key is absent.
+func VerifyAVLProof(root, key []byte, proof *AVLProof) (value []byte, found bool, err error) {
+	if proof == nil {
+		return nil, false, errors.New("missing proof")
+	}
+
+	if proof.Leaf != nil {
+		if !bytes.Equal(proof.Leaf.Key, key) {
+			return nil, false, errors.New("proof is for another key")
+		}
+		if !compareHash(proof.Leaf.rebuild(), root) {
+			return nil, false, errors.New("proof doesn't match the root")
+		}
+		return proof.Leaf.Value, true, nil
+	}
+
+	left, right := proof.Left, proof.Right
+	if left == nil && right == nil {
+		if !compareHash(root, Hash128(nil)) {
+			return nil, false, errors.New("empty proof for a non-empty tree")
+		}
+		return nil, false, nil
+	}
+	for _, neighbour := range []*AVLLeafProof{left, right} {
+		if neighbour != nil && !compareHash(neighbour.rebuild(), root) {
+			return nil, false, errors.New("neighbour proof doesn't match the root")
+		}
+	}
+	if left != nil && bytes.Compare(left.Key, key) >= 0 {
+		return nil, false, errors.New("left neighbour doesn't sort before the key")
+	}
+	if right != nil && bytes.Compare(key, right.Key) >= 0 {
+		return nil, false, errors.New("right neighbour doesn't sort after the key")
+	}
+
+	switch {
+	case left == nil && !avlOnEdge(right.Path, false):
+		return nil, false, errors.New("right neighbour isn't the first leaf")
+	case right == nil && !avlOnEdge(left.Path, true):
+		return nil, false, errors.New("left neighbour isn't the last leaf")
+	case left != nil && right != nil && !avlAdjacent(left, right):
+		return nil, false, errors.New("neighbours aren't adjacent")
+	}
+	return nil, false, nil
+}
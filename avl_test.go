@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// avlKey returns zero padded keys so they sort numerically.
+func avlKey(i int) []byte {
+	return []byte(fmt.Sprintf("key-%03d", i))
+}
+
+// avlTreeOf sets keys 0, step, 2*step, ... below n to "value-<i>".
+func avlTreeOf(n, step int) *AVLTree {
+	tree := NewAVLTree()
+	for i := 0; i < n; i += step {
+		tree.Set(avlKey(i), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	return tree
+}
+
+// avlCheck verifies the AVL invariants and the cached fields of every node.
+func avlCheck(t *testing.T, node *avlNode) {
+	t.Helper()
+	if node == nil || node.isLeaf() {
+		return
+	}
+	if diff := node.left.height - node.right.height; diff < -1 || diff > 1 {
+		t.Errorf("Error: AVLTree: unbalanced node. Expected: |diff| <= 1, Actual: %d\n", diff)
+	}
+	if node.size != node.left.size+node.right.size || !bytes.Equal(node.key, avlLeftmost(node.right).key) {
+		t.Errorf("Error: AVLTree: inner node size or key out of date\n")
+	}
+	avlCheck(t, node.left)
+	avlCheck(t, node.right)
+}
+
+func Test_AVLTree(t *testing.T) {
+	t.Run("Set, Get and Remove", func(t *testing.T) {
+		tree := NewAVLTree()
+		if !bytes.Equal(tree.RootHash(), Hash128(nil)) {
+			t.Errorf("Error: RootHash: empty tree. Expected: %x, Actual: %x\n", Hash128(nil), tree.RootHash())
+		}
+		// Insert in an order that forces every kind of rotation.
+		for _, i := range []int{50, 40, 30, 10, 20, 60, 80, 70, 90, 5, 1} {
+			if tree.Set(avlKey(i), []byte("v")) {
+				t.Errorf("Error: Set: new key %d reported as updated\n", i)
+			}
+		}
+		avlCheck(t, tree.working)
+		if tree.Len() != 11 {
+			t.Errorf("Error: Len: Expected: %v, Actual: %v\n", 11, tree.Len())
+		}
+		if !tree.Set(avlKey(30), []byte("updated")) {
+			t.Errorf("Error: Set: existing key not reported as updated\n")
+		}
+		value, err := tree.Get(avlKey(30))
+		if err != nil || string(value) != "updated" {
+			t.Errorf("Error: Get: Expected: %v, Actual: %s, %v\n", "updated", value, err)
+		}
+		if _, err := tree.Get(avlKey(31)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: Get: missing key. Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+
+		for _, i := range []int{40, 1, 90, 50} {
+			if err := tree.Remove(avlKey(i)); err != nil {
+				t.Errorf("Error: Remove: Expected: %v, Actual: %v\n", nil, err)
+			}
+			avlCheck(t, tree.working)
+		}
+		if err := tree.Remove(avlKey(40)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: Remove: missing key. Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+		if _, err := tree.Get(avlKey(40)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: Get: removed key. Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+		if tree.Len() != 7 {
+			t.Errorf("Error: Len: Expected: %v, Actual: %v\n", 7, tree.Len())
+		}
+
+		// The root commits to the contents, so the tree before the removals
+		// hashes differently.
+		again := NewAVLTree()
+		for _, i := range []int{50, 40, 30, 10, 20, 60, 80, 70, 90, 5, 1} {
+			again.Set(avlKey(i), []byte("v"))
+		}
+		if bytes.Equal(again.RootHash(), tree.RootHash()) {
+			t.Errorf("Error: RootHash: different contents hash the same\n")
+		}
+
+		for _, i := range []int{5, 10, 20, 30, 60, 70, 80} {
+			tree.Remove(avlKey(i))
+		}
+		if tree.Len() != 0 || !bytes.Equal(tree.RootHash(), Hash128(nil)) {
+			t.Errorf("Error: Remove: emptied tree. Expected: %x, Actual: %x\n", Hash128(nil), tree.RootHash())
+		}
+	})
+
+	t.Run("Stays balanced", func(t *testing.T) {
+		tree := avlTreeOf(1000, 1)
+		avlCheck(t, tree.working)
+		limit := int8(1.45 * math.Log2(1000))
+		if tree.working.height > limit {
+			t.Errorf("Error: Set: sequential keys. Expected: height <= %v, Actual: %v\n", limit, tree.working.height)
+		}
+		for i := 0; i < 1000; i += 3 {
+			tree.Remove(avlKey(i))
+		}
+		avlCheck(t, tree.working)
+	})
+
+	t.Run("Iterate", func(t *testing.T) {
+		tree := avlTreeOf(20, 2)
+		collect := func(start, end []byte) []string {
+			keys := []string{}
+			for key, value := range tree.Iterate(start, end) {
+				if !bytes.HasSuffix(value, key[len(key)-1:]) {
+					t.Errorf("Error: Iterate: key %s paired with value %s\n", key, value)
+				}
+				keys = append(keys, string(key))
+			}
+			return keys
+		}
+
+		if keys := collect(nil, nil); len(keys) != 10 || keys[0] != "key-000" || keys[9] != "key-018" {
+			t.Errorf("Error: Iterate: whole tree. Actual: %v\n", keys)
+		}
+		expected := fmt.Sprint([]string{"key-006", "key-008", "key-010"})
+		if keys := collect(avlKey(5), avlKey(12)); fmt.Sprint(keys) != expected {
+			t.Errorf("Error: Iterate: Expected: %v, Actual: %v\n", expected, keys)
+		}
+		expected = fmt.Sprint([]string{"key-006", "key-008"})
+		if keys := collect(avlKey(6), avlKey(10)); fmt.Sprint(keys) != expected {
+			t.Errorf("Error: Iterate: start inclusive, end exclusive. Expected: %v, Actual: %v\n", expected, keys)
+		}
+		if keys := collect(avlKey(15), nil); len(keys) != 2 {
+			t.Errorf("Error: Iterate: open end. Expected: %v, Actual: %v\n", 2, len(keys))
+		}
+
+		count := 0
+		for range tree.Iterate(nil, nil) {
+			count++
+			if count == 3 {
+				break
+			}
+		}
+		if count != 3 {
+			t.Errorf("Error: Iterate: early break. Expected: %v, Actual: %v\n", 3, count)
+		}
+	})
+
+	t.Run("Callers can't change stored keys and values", func(t *testing.T) {
+		tree := avlTreeOf(10, 1)
+		root := tree.RootHash()
+
+		value, _ := tree.Get(avlKey(3))
+		value[0] = 'X'
+		for key, value := range tree.Iterate(nil, nil) {
+			key[0], value[0] = 'X', 'X'
+		}
+		proof := tree.Prove(avlKey(5))
+		proof.Leaf.Key[0], proof.Leaf.Value[0] = 'X', 'X'
+		proof.Leaf.Path[0].Sibling[0]++
+
+		if value, _ := tree.Get(avlKey(3)); string(value) != "value-3" {
+			t.Errorf("Error: Get: Expected: %s, Actual: %s\n", "value-3", value)
+		}
+		if !bytes.Equal(root, tree.RootHash()) {
+			t.Errorf("Error: AVLTree: root changed by mutating returned slices\n")
+		}
+		if value, found, err := VerifyAVLProof(root, avlKey(5), tree.Prove(avlKey(5))); err != nil || !found || string(value) != "value-5" {
+			t.Errorf("Error: VerifyAVLProof: Expected: %s, Actual: %s, %v\n", "value-5", value, err)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		tree := avlTreeOf(10, 1)
+		first, version := tree.SaveVersion()
+		if version != 1 {
+			t.Errorf("Error: SaveVersion: Expected: %v, Actual: %v\n", 1, version)
+		}
+
+		tree.Set(avlKey(3), []byte("changed"))
+		tree.Remove(avlKey(7))
+		second, version := tree.SaveVersion()
+		if version != 2 || bytes.Equal(first, second) {
+			t.Errorf("Error: SaveVersion: second version. Actual: %v, %x\n", version, second)
+		}
+
+		value, err := tree.GetVersioned(avlKey(3), 1)
+		if err != nil || string(value) != "value-3" {
+			t.Errorf("Error: GetVersioned: old value. Expected: %v, Actual: %s, %v\n", "value-3", value, err)
+		}
+		if _, err := tree.GetVersioned(avlKey(7), 1); err != nil {
+			t.Errorf("Error: GetVersioned: key removed later. Expected: %v, Actual: %v\n", nil, err)
+		}
+		if _, err := tree.GetVersioned(avlKey(7), 2); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: GetVersioned: removed key. Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+		if hash, _ := tree.VersionRootHash(1); !bytes.Equal(hash, first) {
+			t.Errorf("Error: VersionRootHash: Expected: %x, Actual: %x\n", first, hash)
+		}
+
+		// Unsaved changes don't leak into saved versions.
+		tree.Set(avlKey(100), []byte("unsaved"))
+		if hash, _ := tree.VersionRootHash(2); !bytes.Equal(hash, second) {
+			t.Errorf("Error: VersionRootHash: after unsaved change. Expected: %x, Actual: %x\n", second, hash)
+		}
+
+		if err := tree.LoadVersion(1); err != nil || !bytes.Equal(tree.RootHash(), first) {
+			t.Errorf("Error: LoadVersion: Expected: %x, Actual: %x, %v\n", first, tree.RootHash(), err)
+		}
+		// Numbers keep counting up after going back.
+		if _, version := tree.SaveVersion(); version != 3 {
+			t.Errorf("Error: SaveVersion: after LoadVersion. Expected: %v, Actual: %v\n", 3, version)
+		}
+
+		if err := tree.DeleteVersion(1); err != nil {
+			t.Errorf("Error: DeleteVersion: Expected: %v, Actual: %v\n", nil, err)
+		}
+		if fmt.Sprint(tree.Versions()) != "[2 3]" {
+			t.Errorf("Error: Versions: Expected: %v, Actual: %v\n", "[2 3]", tree.Versions())
+		}
+		if err := tree.LoadVersion(1); err == nil {
+			t.Errorf("Error: LoadVersion: deleted version loaded\n")
+		}
+		if err := tree.DeleteVersion(9); err == nil {
+			t.Errorf("Error: DeleteVersion: unknown version deleted\n")
+		}
+		if _, err := tree.GetVersioned(avlKey(3), 1); err == nil {
+			t.Errorf("Error: GetVersioned: deleted version read\n")
+		}
+		// Version 3 shares its nodes with the deleted version 1.
+		if value, _ := tree.GetVersioned(avlKey(3), 3); string(value) != "value-3" {
+			t.Errorf("Error: GetVersioned: shared nodes. Expected: %v, Actual: %s\n", "value-3", value)
+		}
+	})
+
+	t.Run("Save and load", func(t *testing.T) {
+		tree := avlTreeOf(30, 1)
+		tree.SaveVersion()
+		tree.Remove(avlKey(4))
+		tree.Set(avlKey(40), []byte("value-40"))
+		tree.SaveVersion()
+		tree.Set(avlKey(41), []byte("unsaved"))
+
+		buffer := &bytes.Buffer{}
+		if err := tree.Save(buffer); err != nil {
+			t.Fatalf("Error: Save: %v\n", err)
+		}
+		saved := buffer.String()
+		loaded, err := LoadAVLTree(bytes.NewBufferString(saved))
+		if err != nil {
+			t.Fatalf("Error: LoadAVLTree: %v\n", err)
+		}
+
+		if !bytes.Equal(loaded.RootHash(), tree.RootHash()) || loaded.Len() != tree.Len() {
+			t.Errorf("Error: LoadAVLTree: working tree. Expected: %x, Actual: %x\n", tree.RootHash(), loaded.RootHash())
+		}
+		for _, version := range tree.Versions() {
+			expected, _ := tree.VersionRootHash(version)
+			actual, err := loaded.VersionRootHash(version)
+			if err != nil || !bytes.Equal(actual, expected) {
+				t.Errorf("Error: LoadAVLTree: version %d. Expected: %x, Actual: %x, %v\n", version, expected, actual, err)
+			}
+		}
+		if _, version := loaded.SaveVersion(); version != 3 {
+			t.Errorf("Error: LoadAVLTree: next version. Expected: %v, Actual: %v\n", 3, version)
+		}
+
+		// Nodes shared between versions are only saved once. Saving each
+		// of the three roots separately would take 179 nodes.
+		decoded := savedAVLTree{}
+		json.Unmarshal([]byte(saved), &decoded)
+		if len(decoded.Nodes) >= 120 {
+			t.Errorf("Error: Save: shared nodes. Expected: < %v, Actual: %v\n", 120, len(decoded.Nodes))
+		}
+
+		tampered := bytes.Replace([]byte(saved), []byte(`"children": [`), []byte(`"children": [1, `), 1)
+		if _, err := LoadAVLTree(bytes.NewBuffer(tampered)); err == nil {
+			t.Errorf("Error: LoadAVLTree: malformed node loaded\n")
+		}
+		// Files whose hashes check out but that Set and Remove could never
+		// have built.
+		leaf := func(key string) savedAVLNode {
+			return savedAVLNode{Key: []byte(key), Value: []byte("v")}
+		}
+		invalid := map[string][]savedAVLNode{
+			"keys out of order": {leaf("b"), leaf("a"), {Children: []int{0, 1}}},
+			"unbalanced node": {
+				leaf("a"), leaf("b"), leaf("c"), leaf("d"),
+				{Children: []int{2, 3}}, {Children: []int{1, 4}}, {Children: []int{0, 5}},
+			},
+		}
+		for reason, nodes := range invalid {
+			built := []*avlNode{}
+			for _, entry := range nodes {
+				if entry.Children == nil {
+					built = append(built, newAVLLeaf(entry.Key, entry.Value))
+				} else {
+					built = append(built, newAVLInner(built[entry.Children[0]], built[entry.Children[1]]))
+				}
+			}
+			root := len(nodes) - 1
+			file, _ := json.Marshal(savedAVLTree{
+				Version: avlFileVersion,
+				Nodes:   nodes,
+				Working: savedAVLRoot{Root: root, Hash: built[root].hash},
+			})
+			if _, err := LoadAVLTree(bytes.NewBuffer(file)); err == nil {
+				t.Errorf("Error: LoadAVLTree: %s loaded\n", reason)
+			}
+		}
+
+		empty := &bytes.Buffer{}
+		NewAVLTree().Save(empty)
+		if loaded, err := LoadAVLTree(empty); err != nil || loaded.Len() != 0 {
+			t.Errorf("Error: LoadAVLTree: empty tree. Actual: %v\n", err)
+		}
+	})
+}
+
+func Test_VerifyAVLProof(t *testing.T) {
+	// Even keys only, so every odd key is absent.
+	tree := avlTreeOf(40, 2)
+	old, version := tree.SaveVersion()
+	tree.Set(avlKey(11), []byte("value-11"))
+	tree.Remove(avlKey(20))
+	root := tree.RootHash()
+
+	t.Run("Existence", func(t *testing.T) {
+		for i := 0; i < 40; i += 2 {
+			if i == 20 {
+				continue
+			}
+			value, found, err := VerifyAVLProof(root, avlKey(i), tree.Prove(avlKey(i)))
+			if err != nil || !found || string(value) != fmt.Sprintf("value-%d", i) {
+				t.Errorf("Error: VerifyAVLProof: key %d. Actual: %s, %v, %v\n", i, value, found, err)
+			}
+		}
+	})
+
+	t.Run("Absence", func(t *testing.T) {
+		// Before the first key, between keys and after the last one.
+		for _, key := range [][]byte{[]byte("a"), avlKey(5), avlKey(20), avlKey(39), []byte("z")} {
+			proof := tree.Prove(key)
+			if proof.Leaf != nil {
+				t.Errorf("Error: Prove: %s reported present\n", key)
+			}
+			if _, found, err := VerifyAVLProof(root, key, proof); err != nil || found {
+				t.Errorf("Error: VerifyAVLProof: absent key %s. Actual: %v, %v\n", key, found, err)
+			}
+		}
+
+		empty := NewAVLTree()
+		if _, found, err := VerifyAVLProof(empty.RootHash(), avlKey(1), empty.Prove(avlKey(1))); err != nil || found {
+			t.Errorf("Error: VerifyAVLProof: empty tree. Actual: %v, %v\n", found, err)
+		}
+	})
+
+	t.Run("Old versions", func(t *testing.T) {
+		proof, err := tree.ProveVersion(avlKey(20), version)
+		if err != nil {
+			t.Fatalf("Error: ProveVersion: %v\n", err)
+		}
+		if _, found, err := VerifyAVLProof(old, avlKey(20), proof); err != nil || !found {
+			t.Errorf("Error: VerifyAVLProof: key removed later. Actual: %v, %v\n", found, err)
+		}
+		proof, _ = tree.ProveVersion(avlKey(11), version)
+		if _, found, err := VerifyAVLProof(old, avlKey(11), proof); err != nil || found {
+			t.Errorf("Error: VerifyAVLProof: key added later. Actual: %v, %v\n", found, err)
+		}
+		if _, err := tree.ProveVersion(avlKey(11), version+1); err == nil {
+			t.Errorf("Error: ProveVersion: unknown version proved\n")
+		}
+	})
+
+	t.Run("Forged proofs", func(t *testing.T) {
+		proof := tree.Prove(avlKey(10))
+		if _, _, err := VerifyAVLProof(root, avlKey(12), proof); err == nil {
+			t.Errorf("Error: VerifyAVLProof: proof for another key accepted\n")
+		}
+		if _, _, err := VerifyAVLProof(old, avlKey(10), proof); err == nil {
+			t.Errorf("Error: VerifyAVLProof: proof against another root accepted\n")
+		}
+
+		tampered := *proof.Leaf
+		tampered.Value = []byte("forged")
+		if _, _, err := VerifyAVLProof(root, avlKey(10), &AVLProof{Key: avlKey(10), Leaf: &tampered}); err == nil {
+			t.Errorf("Error: VerifyAVLProof: tampered value accepted\n")
+		}
+
+		// Claim key 12 is absent using leaves 10 and 14, which aren't
+		// adjacent.
+		forged := &AVLProof{Key: avlKey(12), Left: tree.Prove(avlKey(10)).Leaf, Right: tree.Prove(avlKey(14)).Leaf}
+		if _, _, err := VerifyAVLProof(root, avlKey(12), forged); err == nil {
+			t.Errorf("Error: VerifyAVLProof: non adjacent neighbours accepted\n")
+		}
+		// Only a right neighbour that isn't the first leaf.
+		forged = &AVLProof{Key: avlKey(12), Right: tree.Prove(avlKey(14)).Leaf}
+		if _, _, err := VerifyAVLProof(root, avlKey(12), forged); err == nil {
+			t.Errorf("Error: VerifyAVLProof: fake left edge accepted\n")
+		}
+		// Only a left neighbour that isn't the last leaf.
+		forged = &AVLProof{Key: avlKey(12), Left: tree.Prove(avlKey(10)).Leaf}
+		if _, _, err := VerifyAVLProof(root, avlKey(12), forged); err == nil {
+			t.Errorf("Error: VerifyAVLProof: fake right edge accepted\n")
+		}
+		// Neighbours that don't bracket the key.
+		absent := tree.Prove(avlKey(5))
+		if _, _, err := VerifyAVLProof(root, avlKey(12), absent); err == nil {
+			t.Errorf("Error: VerifyAVLProof: neighbours of another gap accepted\n")
+		}
+		if _, _, err := VerifyAVLProof(root, avlKey(12), &AVLProof{Key: avlKey(12)}); err == nil {
+			t.Errorf("Error: VerifyAVLProof: empty proof for a non-empty tree accepted\n")
+		}
+		if _, _, err := VerifyAVLProof(root, avlKey(12), nil); err == nil {
+			t.Errorf("Error: VerifyAVLProof: nil proof accepted\n")
+		}
+	})
+}
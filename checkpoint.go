@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Checkpoints and signed notes follow the C2SP specifications
+// (c2sp.org/tlog-checkpoint and c2sp.org/signed-note) so roots can be
+// exchanged with existing transparency tooling. A signed checkpoint looks
+// like:
+//
+//	example.com/log
+//	27
+//	hHo1p7UcS+VN3jJOlbxgBw==
+//
+//	— example.com/log Az3grlgv...
+//	— witness.example.com Bx8F1m0p...
+
+// maxNoteSignatures bounds how many signature lines OpenNote will look at.
+const maxNoteSignatures = 100
+
+// noteAlgEd25519 identifies Ed25519 in signed-note key IDs and key strings.
+const noteAlgEd25519 = 0x01
+
+// Checkpoint is the body of a C2SP checkpoint: the log's origin, its size
+// and its root hash, optionally followed by extension lines.
+type Checkpoint struct {
+	Origin     string
+	Size       uint64
+	Hash       []byte
+	Extensions []string
+}
+
+// Checkpoint returns the checkpoint for the tree's current root. The size
+// is the number of leaves.
+func (merkelTree *MerkelTree) Checkpoint(origin string) Checkpoint {
+	return Checkpoint{Origin: origin, Size: uint64(len(merkelTree.Leaves())), Hash: merkelTree.rootHash()}
+}
+
+// Checkpoint returns the checkpoint for the log's current root.
+func (merkelLog *MerkelLog) Checkpoint(origin string) Checkpoint {
+	return Checkpoint{Origin: origin, Size: merkelLog.Size(), Hash: merkelLog.RootHash()}
+}
+
+// Marshal encodes the checkpoint body.
+func (checkpoint Checkpoint) Marshal() []byte {
+	var text bytes.Buffer
+	fmt.Fprintf(&text, "%s\n%d\n%s\n", checkpoint.Origin, checkpoint.Size, base64.StdEncoding.EncodeToString(checkpoint.Hash))
+	for _, extension := range checkpoint.Extensions {
+		fmt.Fprintf(&text, "%s\n", extension)
+	}
+	return text.Bytes()
+}
+
+// ParseCheckpoint decodes a checkpoint body, i.e. the text of a signed note.
+func ParseCheckpoint(text []byte) (*Checkpoint, error) {
+	if !bytes.HasSuffix(text, []byte("\n")) {
+		return nil, errors.New("checkpoint must end with a newline")
+	}
+	lines := strings.Split(string(text[:len(text)-1]), "\n")
+	if len(lines) < 3 {
+		return nil, errors.New("checkpoint needs an origin, a size and a root hash")
+	}
+
+	checkpoint := &Checkpoint{Origin: lines[0]}
+	if checkpoint.Origin == "" {
+		return nil, errors.New("checkpoint origin is empty")
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil || strconv.FormatUint(size, 10) != lines[1] {
+		return nil, fmt.Errorf("invalid checkpoint size %q", lines[1])
+	}
+	checkpoint.Size = size
+	if checkpoint.Hash, err = base64.StdEncoding.Strict().DecodeString(lines[2]); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint root hash: %w", err)
+	}
+	for _, extension := range lines[3:] {
+		if extension == "" {
+			return nil, errors.New("checkpoint contains an empty line")
+		}
+		checkpoint.Extensions = append(checkpoint.Extensions, extension)
+	}
+
+	return checkpoint, nil
+}
+
+// noteKeyID computes the signed-note key ID: the first 4 bytes of
+// SHA-256(name || "\n" || alg || public key).
+func noteKeyID(name string, key ed25519.PublicKey) uint32 {
+	hasher := sha256.New()
+	hasher.Write([]byte(name + "\n"))
+	hasher.Write([]byte{noteAlgEd25519})
+	hasher.Write(key)
+	return binary.BigEndian.Uint32(hasher.Sum(nil))
+}
+
+// validNoteName reports whether name can be used as a key name: non-empty,
+// valid UTF-8, no whitespace and no "+".
+func validNoteName(name string) bool {
+	if name == "" || !utf8.ValidString(name) || strings.Contains(name, "+") {
+		return false
+	}
+	return strings.IndexFunc(name, unicode.IsSpace) < 0
+}
+
+// NoteSigner signs notes with an Ed25519 key under a name.
+type NoteSigner struct {
+	name  string
+	keyID uint32
+	key   ed25519.PrivateKey
+}
+
+// NewNoteSigner creates a signer; name is usually the log origin or the
+// witness name.
+func NewNoteSigner(name string, key ed25519.PrivateKey) (*NoteSigner, error) {
+	if !validNoteName(name) {
+		return nil, fmt.Errorf("invalid key name %q", name)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+	publicKey := key.Public().(ed25519.PublicKey)
+	return &NoteSigner{name: name, keyID: noteKeyID(name, publicKey), key: key}, nil
+}
+
+// Name returns the signer's key name.
+func (signer *NoteSigner) Name() string {
+	return signer.name
+}
+
+// Verifier returns the verifier matching the signer.
+func (signer *NoteSigner) Verifier() *NoteVerifier {
+	return &NoteVerifier{name: signer.name, keyID: signer.keyID, key: signer.key.Public().(ed25519.PublicKey)}
+}
+
+// NoteVerifier checks note signatures made by a single named key.
+type NoteVerifier struct {
+	name  string
+	keyID uint32
+	key   ed25519.PublicKey
+}
+
+// NewNoteVerifier creates a verifier for name's public key.
+func NewNoteVerifier(name string, key ed25519.PublicKey) (*NoteVerifier, error) {
+	if !validNoteName(name) {
+		return nil, fmt.Errorf("invalid key name %q", name)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	return &NoteVerifier{name: name, keyID: noteKeyID(name, key), key: key}, nil
+}
+
+// ParseNoteVerifier parses a verifier key in the usual
+// "<name>+<hex key ID>+<base64(alg || public key)>" form.
+func ParseNoteVerifier(vkey string) (*NoteVerifier, error) {
+	name, rest, ok := strings.Cut(vkey, "+")
+	if !ok {
+		return nil, errors.New("malformed verifier key")
+	}
+	idHex, keyBase64, ok := strings.Cut(rest, "+")
+	if !ok || len(idHex) != 8 {
+		return nil, errors.New("malformed verifier key")
+	}
+	keyBytes, err := base64.StdEncoding.DecodeString(keyBase64)
+	if err != nil || len(keyBytes) != 1+ed25519.PublicKeySize || keyBytes[0] != noteAlgEd25519 {
+		return nil, errors.New("verifier key isn't an ed25519 key")
+	}
+
+	verifier, err := NewNoteVerifier(name, ed25519.PublicKey(keyBytes[1:]))
+	if err != nil {
+		return nil, err
+	}
+	if fmt.Sprintf("%08x", verifier.keyID) != strings.ToLower(idHex) {
+		return nil, errors.New("verifier key ID doesn't match the key")
+	}
+	return verifier, nil
+}
+
+// Name returns the verifier's key name.
+func (verifier *NoteVerifier) Name() string {
+	return verifier.name
+}
+
+// String encodes the verifier key so it can be shared and parsed with
+// ParseNoteVerifier.
+func (verifier *NoteVerifier) String() string {
+	keyBytes := append([]byte{noteAlgEd25519}, verifier.key...)
+	return fmt.Sprintf("%s+%08x+%s", verifier.name, verifier.keyID, base64.StdEncoding.EncodeToString(keyBytes))
+}
+
+// NoteSignature is a single signature line of a signed note.
+type NoteSignature struct {
+	Name      string
+	KeyID     uint32
+	Signature []byte
+}
+
+func (signature NoteSignature) line() string {
+	raw := binary.BigEndian.AppendUint32(nil, signature.KeyID)
+	raw = append(raw, signature.Signature...)
+	return fmt.Sprintf("— %s %s\n", signature.Name, base64.StdEncoding.EncodeToString(raw))
+}
+
+// splitNote separates a signed note into its text and signature lines.
+func splitNote(note []byte) ([]byte, []NoteSignature, error) {
+	split := bytes.LastIndex(note, []byte("\n\n"))
+	if split < 0 {
+		return nil, nil, errors.New("malformed note: no signatures")
+	}
+	text, block := note[:split+1], note[split+2:]
+	if !utf8.Valid(note) {
+		return nil, nil, errors.New("malformed note: invalid UTF-8")
+	}
+	if !bytes.HasSuffix(block, []byte("\n")) {
+		return nil, nil, errors.New("malformed note: signatures must end with a newline")
+	}
+
+	signatures := []NoteSignature{}
+	for _, line := range strings.Split(string(block[:len(block)-1]), "\n") {
+		if len(signatures) == maxNoteSignatures {
+			return nil, nil, errors.New("malformed note: too many signatures")
+		}
+		rest, ok := strings.CutPrefix(line, "— ")
+		if !ok {
+			return nil, nil, fmt.Errorf("malformed note: bad signature line %q", line)
+		}
+		name, encoded, ok := strings.Cut(rest, " ")
+		if !ok || !validNoteName(name) {
+			return nil, nil, fmt.Errorf("malformed note: bad signature line %q", line)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) < 5 {
+			return nil, nil, fmt.Errorf("malformed note: bad signature line %q", line)
+		}
+		signatures = append(signatures, NoteSignature{
+			Name:      name,
+			KeyID:     binary.BigEndian.Uint32(raw),
+			Signature: raw[4:],
+		})
+	}
+
+	return text, signatures, nil
+}
+
+// SignNote signs text with every signer. text must end with a newline and
+// can't contain a blank line at its end.
+func SignNote(text []byte, signers ...*NoteSigner) ([]byte, error) {
+	if !bytes.HasSuffix(text, []byte("\n")) || bytes.HasSuffix(text, []byte("\n\n")) || !utf8.Valid(text) {
+		return nil, errors.New("note text must be UTF-8 ending in a single newline")
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("no signers")
+	}
+
+	note := append(append([]byte{}, text...), '\n')
+	for _, signer := range signers {
+		signature := NoteSignature{Name: signer.name, KeyID: signer.keyID, Signature: ed25519.Sign(signer.key, text)}
+		note = append(note, signature.line()...)
+	}
+	return note, nil
+}
+
+// CosignNote adds the signatures of more signers to an already signed note,
+// keeping the existing ones. A signer that already signed isn't added twice.
+func CosignNote(note []byte, signers ...*NoteSigner) ([]byte, error) {
+	text, signatures, err := splitNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	cosigned := append([]byte{}, note...)
+	for _, signer := range signers {
+		signed := false
+		for _, signature := range signatures {
+			signed = signed || (signature.Name == signer.name && signature.KeyID == signer.keyID)
+		}
+		if signed {
+			continue
+		}
+		signature := NoteSignature{Name: signer.name, KeyID: signer.keyID, Signature: ed25519.Sign(signer.key, text)}
+		cosigned = append(cosigned, signature.line()...)
+		signatures = append(signatures, signature)
+	}
+	return cosigned, nil
+}
+
+// OpenNote verifies a signed note and returns its text along with the
+// signatures that were verified. Signatures from unknown keys are ignored,
+// but a signature from a known key that doesn't verify is an error, and at
+// least one signature must verify.
+func OpenNote(note []byte, verifiers ...*NoteVerifier) ([]byte, []NoteSignature, error) {
+	text, signatures, err := splitNote(note)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verified := []NoteSignature{}
+	for _, signature := range signatures {
+		for _, verifier := range verifiers {
+			if verifier.name != signature.Name || verifier.keyID != signature.KeyID {
+				continue
+			}
+			if !ed25519.Verify(verifier.key, text, signature.Signature) {
+				return nil, nil, fmt.Errorf("invalid signature from %s", signature.Name)
+			}
+			verified = append(verified, signature)
+		}
+	}
+	if len(verified) == 0 {
+		return nil, nil, errors.New("note has no signature from a known key")
+	}
+
+	return text, verified, nil
+}
+
+// SignCheckpoint encodes the checkpoint and signs it with every signer.
+func SignCheckpoint(checkpoint Checkpoint, signers ...*NoteSigner) ([]byte, error) {
+	return SignNote(checkpoint.Marshal(), signers...)
+}
+
+// OpenCheckpoint verifies a signed checkpoint and parses it.
+func OpenCheckpoint(note []byte, verifiers ...*NoteVerifier) (*Checkpoint, []NoteSignature, error) {
+	text, verified, err := OpenNote(note, verifiers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	checkpoint, err := ParseCheckpoint(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	return checkpoint, verified, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Checkpoint(t *testing.T) {
+	t.Run("Marshal and parse round trip", func(t *testing.T) {
+		checkpoint := Checkpoint{
+			Origin:     "example.com/log",
+			Size:       27,
+			Hash:       Hash128([]byte("root")),
+			Extensions: []string{"extension one", "extension two"},
+		}
+		parsed, err := ParseCheckpoint(checkpoint.Marshal())
+		if err != nil {
+			t.Fatalf("Error: ParseCheckpoint: %+v\n", err)
+		}
+		if parsed.Origin != checkpoint.Origin || parsed.Size != checkpoint.Size || !bytes.Equal(parsed.Hash, checkpoint.Hash) {
+			t.Errorf("Error: ParseCheckpoint: Expected: %+v, Actual: %+v\n", checkpoint, parsed)
+		}
+		if len(parsed.Extensions) != 2 || parsed.Extensions[1] != "extension two" {
+			t.Errorf("Error: ParseCheckpoint: extensions lost: %v\n", parsed.Extensions)
+		}
+	})
+
+	t.Run("Malformed checkpoints", func(t *testing.T) {
+		for _, text := range []string{
+			"example.com/log\n27\n",
+			"example.com/log\n27\nhHo1p7UcS+VN3jJOlbxgBw==",
+			"example.com/log\n027\nhHo1p7UcS+VN3jJOlbxgBw==\n",
+			"example.com/log\n-1\nhHo1p7UcS+VN3jJOlbxgBw==\n",
+			"example.com/log\n27\nnot base64\n",
+			"\n27\nhHo1p7UcS+VN3jJOlbxgBw==\n",
+		} {
+			if _, err := ParseCheckpoint([]byte(text)); err == nil {
+				t.Errorf("Error: ParseCheckpoint: accepted %q\n", text)
+			}
+		}
+	})
+
+	t.Run("MerkelTree and MerkelLog checkpoints", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		checkpoint := testMerkelTree.Checkpoint("example.com/tree")
+		if checkpoint.Size != 2 || !bytes.Equal(checkpoint.Hash, testMerkelTree.root.hash) {
+			t.Errorf("Error: Checkpoint: tree checkpoint mismatch: %+v\n", checkpoint)
+		}
+
+		log, _ := testLog(5)
+		checkpoint = log.Checkpoint("example.com/log")
+		if checkpoint.Size != 5 || !bytes.Equal(checkpoint.Hash, log.RootHash()) {
+			t.Errorf("Error: Checkpoint: log checkpoint mismatch: %+v\n", checkpoint)
+		}
+	})
+}
+
+func Test_SignedNote(t *testing.T) {
+	logSigner, _ := NewNoteSigner("example.com/log", testKey(1))
+	witnessSigner, _ := NewNoteSigner("witness.example.com", testKey(2))
+	log, _ := testLog(7)
+
+	t.Run("Sign, cosign and open", func(t *testing.T) {
+		note, err := SignCheckpoint(log.Checkpoint("example.com/log"), logSigner)
+		if err != nil {
+			t.Fatalf("Error: SignCheckpoint: %+v\n", err)
+		}
+		note, err = CosignNote(note, witnessSigner, logSigner)
+		if err != nil {
+			t.Fatalf("Error: CosignNote: %+v\n", err)
+		}
+		if strings.Count(string(note), "\n— ") != 2 {
+			t.Errorf("Error: CosignNote: expected 2 signatures:\n%s\n", note)
+		}
+
+		checkpoint, verified, err := OpenCheckpoint(note, logSigner.Verifier(), witnessSigner.Verifier())
+		if err != nil {
+			t.Fatalf("Error: OpenCheckpoint: %+v\n", err)
+		}
+		if len(verified) != 2 || checkpoint.Size != 7 || !bytes.Equal(checkpoint.Hash, log.RootHash()) {
+			t.Errorf("Error: OpenCheckpoint: Expected: 2 signatures over size 7, Actual: %d over %+v\n", len(verified), checkpoint)
+		}
+
+		// Unknown signatures are ignored as long as one known key verifies.
+		if _, verified, err = OpenCheckpoint(note, witnessSigner.Verifier()); err != nil || len(verified) != 1 {
+			t.Errorf("Error: OpenCheckpoint: witness only: %+v, %d\n", err, len(verified))
+		}
+		otherSigner, _ := NewNoteSigner("other.example.com", testKey(3))
+		if _, _, err = OpenCheckpoint(note, otherSigner.Verifier()); err == nil {
+			t.Errorf("Error: OpenCheckpoint: note without a known signature accepted")
+		}
+	})
+
+	t.Run("Tampered note", func(t *testing.T) {
+		note, _ := SignCheckpoint(log.Checkpoint("example.com/log"), logSigner)
+		tampered := bytes.Replace(note, []byte("\n7\n"), []byte("\n8\n"), 1)
+		if _, _, err := OpenCheckpoint(tampered, logSigner.Verifier()); err == nil {
+			t.Errorf("Error: OpenCheckpoint: tampered size accepted")
+		}
+		if _, _, err := OpenNote(note[:len(note)-1], logSigner.Verifier()); err == nil {
+			t.Errorf("Error: OpenNote: truncated note accepted")
+		}
+	})
+
+	t.Run("Verifier key strings", func(t *testing.T) {
+		vkey := logSigner.Verifier().String()
+		verifier, err := ParseNoteVerifier(vkey)
+		if err != nil {
+			t.Fatalf("Error: ParseNoteVerifier: %+v\n", err)
+		}
+		if verifier.String() != vkey || verifier.Name() != "example.com/log" {
+			t.Errorf("Error: ParseNoteVerifier: Expected: %s, Actual: %s\n", vkey, verifier.String())
+		}
+		if _, err := ParseNoteVerifier(strings.Replace(vkey, "+", "+0", 1)); err == nil {
+			t.Errorf("Error: ParseNoteVerifier: bad key ID accepted")
+		}
+		if _, err := NewNoteSigner("has space", testKey(1)); err == nil {
+			t.Errorf("Error: NewNoteSigner: invalid name accepted")
+		}
+	})
+
+	t.Run("Known signed note", func(t *testing.T) {
+		// The example from the Go sumdb note package documentation.
+		verifier, err := ParseNoteVerifier("PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW")
+		if err != nil {
+			t.Fatalf("Error: ParseNoteVerifier: %+v\n", err)
+		}
+		note := "If you think cryptography is the answer to your problem,\n" +
+			"then you don't know what your problem is.\n" +
+			"\n" +
+			"— PeterNeumann x08go/ZJkuBS9UG/SffcvIAQxVBtiFupLLr8pAcElZInNIuGUgYN1FFYC2pZSNXgKvqfqdngotpRZb6KE6RyyBwJnAM=\n"
+		if _, _, err := OpenNote([]byte(note), verifier); err != nil {
+			t.Errorf("Error: OpenNote: %+v\n", err)
+		}
+	})
+}
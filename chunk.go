@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultChunkSize is the chunk size used when HashChunks is given 0.
+const DefaultChunkSize = 1 << 20
+
+// ChunkedFile is a merkel tree over the fixed-size chunks of a file. The
+// tree has the MerkelLog shape and hashing, so its nodes stay 32 bytes
+// however large the file is. The chunks themselves are never kept, only
+// the hashes of the tree, about 64 bytes per chunk, which is what
+// ProveChunk needs.
+type ChunkedFile struct {
+	ChunkSize int
+	Size      int64
+	chunks    uint64
+	levels    hashLevels
+}
+
+// ChunkProof proves the content of one chunk of a ChunkedFile. Siblings run
+// from the chunk's leaf up to the root, as in MerkelLog.InclusionProof; the
+// directions follow from the chunk index and count, which the verifier
+// supplies.
+type ChunkProof struct {
+	Siblings [][]byte
+}
+
+// chunkLeaf builds the leaf data for a chunk: the chunk index as a big
+// endian uint64 followed by the SHA-256 of the chunk. The index makes
+// identical chunks distinct leaves and ties every proof to a position.
+func chunkLeaf(index int, chunk []byte) []byte {
+	digest := sha256.Sum256(chunk)
+	leaf := binary.BigEndian.AppendUint64(nil, uint64(index))
+	return append(leaf, digest[:]...)
+}
+
+// HashChunks streams r in chunkSize pieces and pushes the chunk hashes into
+// the same hashLevels MerkelLog keeps, so every complete subtree is hashed
+// once as it fills up. Only one chunk is read into memory at a time; the
+// last chunk may be shorter than chunkSize.
+func HashChunks(r io.Reader, chunkSize int) (*ChunkedFile, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+
+	chunked := &ChunkedFile{
+		ChunkSize: chunkSize,
+		levels:    hashLevels{},
+	}
+	buffer := make([]byte, chunkSize)
+	for {
+		read, err := io.ReadFull(r, buffer)
+		if read > 0 {
+			chunked.levels.push(LogLeafHash(chunkLeaf(int(chunked.chunks), buffer[:read])))
+			chunked.chunks++
+			chunked.Size += int64(read)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return chunked, nil
+}
+
+// Root returns the root hash over every chunk, nil for empty input.
+func (chunked *ChunkedFile) Root() []byte {
+	if chunked.chunks == 0 {
+		return nil
+	}
+	root, _ := rootHash(chunked.levels, chunked.chunks)
+	return root
+}
+
+// Chunks returns the number of chunks.
+func (chunked *ChunkedFile) Chunks() int {
+	return int(chunked.chunks)
+}
+
+// ProveChunk generates the proof for the chunk at index.
+func (chunked *ChunkedFile) ProveChunk(index int) (*ChunkProof, error) {
+	if index < 0 || uint64(index) >= chunked.chunks {
+		return nil, fmt.Errorf("chunk %d out of range [0, %d)", index, chunked.chunks)
+	}
+
+	siblings, err := inclusionProof(chunked.levels, uint64(index), chunked.chunks)
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkProof{Siblings: siblings}, nil
+}
+
+// VerifyChunk checks that chunk is the content of chunk number index of a
+// file with the given root and number of chunks, using only the chunk and
+// its proof.
+func VerifyChunk(root []byte, index, chunks int, chunk []byte, proof *ChunkProof) error {
+	if proof == nil {
+		return errors.New("no proof")
+	}
+	if index < 0 || chunks <= 0 {
+		return fmt.Errorf("chunk %d out of range [0, %d)", index, chunks)
+	}
+
+	leafHash := LogLeafHash(chunkLeaf(index, chunk))
+	if err := VerifyInclusion(leafHash, uint64(index), uint64(chunks), proof.Siblings, root); err != nil {
+		return fmt.Errorf("chunk %d: %w", index, err)
+	}
+	return nil
+}
+
+// ReadChunkAt reads chunk number index from a file of the given size
+// without touching any other part of it.
+func ReadChunkAt(file io.ReaderAt, size int64, chunkSize, index int) ([]byte, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+	offset := int64(index) * int64(chunkSize)
+	if index < 0 || offset >= size {
+		return nil, fmt.Errorf("chunk %d is outside of the file", index)
+	}
+
+	length := int64(chunkSize)
+	if offset+length > size {
+		length = size - offset
+	}
+	chunk := make([]byte, length)
+	if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return chunk, nil
+}
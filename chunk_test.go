@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.ReaderAt
+	read   int
+}
+
+func (counting *countingReader) ReadAt(p []byte, offset int64) (int, error) {
+	read, err := counting.reader.ReadAt(p, offset)
+	counting.read += read
+	return read, err
+}
+
+func Test_HashChunks(t *testing.T) {
+	// 10 chunks of 64 bytes and a final 36 byte chunk. Chunks 0 and 1 are
+	// identical on purpose.
+	file := bytes.Repeat([]byte("0123456789abcdef"), 8)
+	for index := 0; index < 38; index++ {
+		file = append(file, bytes.Repeat([]byte{byte(index)}, 16)...)
+	}
+	file = file[:676]
+
+	chunked, err := HashChunks(bytes.NewReader(file), 64)
+	if err != nil {
+		t.Fatalf("Error: HashChunks: %+v\n", err)
+	}
+
+	t.Run("Chunk count and size", func(t *testing.T) {
+		if chunked.Chunks() != 11 || chunked.Size != 676 {
+			t.Errorf("Error: HashChunks: Expected 11 chunks / 676 bytes, Actual: %d / %d\n", chunked.Chunks(), chunked.Size)
+		}
+		again, _ := HashChunks(bytes.NewReader(file), 64)
+		if !bytes.Equal(again.Root(), chunked.Root()) {
+			t.Errorf("Error: HashChunks: root isn't deterministic")
+		}
+	})
+
+	t.Run("Root matches a log of the chunk leaves", func(t *testing.T) {
+		merkelLog := NewMerkelLog()
+		for index := 0; index*64 < len(file); index++ {
+			merkelLog.Append(chunkLeaf(index, file[index*64:min((index+1)*64, len(file))]))
+		}
+		if !bytes.Equal(chunked.Root(), merkelLog.RootHash()) || len(chunked.Root()) != 32 {
+			t.Errorf("Error: HashChunks: Expected: %x, Actual: %x\n", merkelLog.RootHash(), chunked.Root())
+		}
+	})
+
+	t.Run("Verify every chunk on its own", func(t *testing.T) {
+		reader := &countingReader{reader: bytes.NewReader(file)}
+		for index := 0; index < chunked.Chunks(); index++ {
+			proof, err := chunked.ProveChunk(index)
+			if err != nil {
+				t.Fatalf("Error: ProveChunk: %+v\n", err)
+			}
+			chunk, err := ReadChunkAt(reader, chunked.Size, chunked.ChunkSize, index)
+			if err != nil {
+				t.Fatalf("Error: ReadChunkAt: %+v\n", err)
+			}
+			if err := VerifyChunk(chunked.Root(), index, chunked.Chunks(), chunk, proof); err != nil {
+				t.Errorf("Error: VerifyChunk: %+v\n", err)
+			}
+		}
+		if reader.read != len(file) {
+			t.Errorf("Error: ReadChunkAt: read %d bytes for a %d byte file\n", reader.read, len(file))
+		}
+	})
+
+	t.Run("Corrupted or misplaced chunks fail", func(t *testing.T) {
+		proof, _ := chunked.ProveChunk(3)
+		chunk := append([]byte{}, file[3*64:4*64]...)
+		chunk[10] ^= 0xff
+		if err := VerifyChunk(chunked.Root(), 3, chunked.Chunks(), chunk, proof); err == nil {
+			t.Errorf("Error: VerifyChunk: corrupted chunk verified")
+		}
+
+		// Chunks 0 and 1 hold the same bytes but are different leaves.
+		proof, _ = chunked.ProveChunk(0)
+		if err := VerifyChunk(chunked.Root(), 1, chunked.Chunks(), file[:64], proof); err == nil {
+			t.Errorf("Error: VerifyChunk: chunk verified at the wrong index")
+		}
+
+		// The chunk count comes from the verifier and fixes the shape.
+		proof, _ = chunked.ProveChunk(10)
+		if err := VerifyChunk(chunked.Root(), 10, 12, file[640:], proof); err == nil {
+			t.Errorf("Error: VerifyChunk: proof accepted for another chunk count")
+		}
+		if err := VerifyChunk(chunked.Root(), 0, chunked.Chunks(), file[:64], nil); err == nil {
+			t.Errorf("Error: VerifyChunk: missing proof accepted")
+		}
+
+		if _, err := chunked.ProveChunk(11); err == nil {
+			t.Errorf("Error: ProveChunk: out of range chunk not rejected")
+		}
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		empty, err := HashChunks(bytes.NewReader(nil), 0)
+		if err != nil || empty.Chunks() != 0 || empty.Root() != nil || empty.ChunkSize != DefaultChunkSize {
+			t.Errorf("Error: HashChunks: empty input: %+v %+v\n", empty, err)
+		}
+	})
+}
@@ -0,0 +1,626 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exit codes returned by the command line tool.
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+	exitDuplicate
+	exitInvalidProof
+)
+
+var (
+	errUsage        = errors.New("invalid usage")
+	errInvalidProof = errors.New("proof is invalid")
+)
+
+// cli holds the state shared by every subcommand.
+type cli struct {
+	treePath string
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"init":   {"init [-force] [-sorted]", (*cli).runInit},
+	"add":    {"add [-o hex|json] [-file PATH]... [DATA|-]...", (*cli).runAdd},
+	"update": {"update [-o hex|json] [-file PATH] HASH [DATA|-]", (*cli).runUpdate},
+	"get":    {"get [-o raw|hex|json] HASH", (*cli).runGet},
+	"delete": {"delete HASH", (*cli).runDelete},
+	"prove":  {"prove [-o json|hex] HASH", (*cli).runProve},
+	"verify": {"verify [-root HEX] [PROOF_FILE|-]", (*cli).runVerify},
+	"show":   {"show [-format text|dot|mermaid|json|html] [-hashes] [-hash-length N] [-depth] [-prove HASH]", (*cli).runShow},
+	"root":   {"root", (*cli).runRoot},
+	"stats":  {"stats", (*cli).runStats},
+	"serve":  {"serve [-addr ADDR]", (*cli).runServe},
+}
+
+// runCLI parses the command line and runs the requested subcommand against
+// the tree file, returning the process exit code. Without a subcommand the
+// demo is run instead.
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("merkel", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	treePath := global.String("tree", "merkel.tree", "path of the tree file")
+	printStats := global.Bool("stats", false, "print tree statistics once the demo has run")
+	global.Usage = func() {
+		fmt.Fprintf(stderr, "usage: merkel [-tree FILE] COMMAND [ARGS]\n\ncommands:\n")
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %s\n", commands[name].usage)
+		}
+		fmt.Fprintf(stderr, "\nWithout a command the demo is run.\n\nflags:\n")
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if global.NArg() == 0 {
+		runDemo(*printStats)
+		return exitOK
+	}
+
+	cmd, ok := commands[global.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "merkel: unknown command %q\n", global.Arg(0))
+		global.Usage()
+		return exitUsage
+	}
+
+	c := &cli{
+		treePath: *treePath,
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+	}
+	err := cmd.run(c, global.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "merkel: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: merkel %s\n", cmd.usage)
+		}
+	}
+
+	return exitCode(err)
+}
+
+// exitCode maps an error onto one of the documented exit codes.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.Is(err, ErrHashNotFound):
+		return exitNotFound
+	case errors.Is(err, ErrHashExists):
+		return exitDuplicate
+	case errors.Is(err, errInvalidProof):
+		return exitInvalidProof
+	default:
+		return exitError
+	}
+}
+
+// flags creates the flag set for a subcommand.
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+// parse parses a subcommand's flags, wrapping failures as usage errors.
+func parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// loadTree reads the tree file.
+func (c *cli) loadTree() (*MerkelTree, error) {
+	file, err := os.Open(c.treePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("tree file %s doesn't exist, create it with init", c.treePath)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadMerkelTree(file)
+}
+
+// saveTree writes the tree file through a temporary file so a failed write
+// never leaves a half written tree behind.
+func (c *cli) saveTree(tree *MerkelTree) error {
+	temp, err := os.CreateTemp(filepath.Dir(c.treePath), filepath.Base(c.treePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := tree.Save(temp); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), c.treePath)
+}
+
+// parseHash decodes a hex hash given on the command line.
+func parseHash(arg string) ([]byte, error) {
+	hash, err := hex.DecodeString(arg)
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("%w: %q is not a hex hash", errUsage, arg)
+	}
+	return hash, nil
+}
+
+// readData returns the data named by arg; "-" reads stdin.
+func (c *cli) readData(arg string) ([]byte, error) {
+	if arg == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return []byte(arg), nil
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// checkFormat validates an output format flag.
+func checkFormat(format string, allowed ...string) error {
+	for _, option := range allowed {
+		if format == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown output format %q", errUsage, format)
+}
+
+// printHash writes a hash in the requested output format.
+func (c *cli) printHash(format string, hash []byte) error {
+	if format == "json" {
+		return json.NewEncoder(c.stdout).Encode(map[string]string{"hash": hex.EncodeToString(hash)})
+	}
+	_, err := fmt.Fprintln(c.stdout, hex.EncodeToString(hash))
+	return err
+}
+
+func (c *cli) runInit(args []string) error {
+	flags := c.flags("init")
+	force := flags.Bool("force", false, "overwrite an existing tree file")
+	sorted := flags.Bool("sorted", false, "keep leaves sorted by hash so the root only depends on the set of leaves")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	if !*force {
+		if _, err := os.Stat(c.treePath); err == nil {
+			return fmt.Errorf("tree file %s already exists, use -force to overwrite it", c.treePath)
+		}
+	}
+
+	if *sorted {
+		return c.saveTree(InitSortedMerkelTree())
+	}
+	return c.saveTree(InitMerkelTree())
+}
+
+func (c *cli) runAdd(args []string) error {
+	flags := c.flags("add")
+	format := flags.String("o", "hex", "output format: hex or json")
+	files := stringList{}
+	flags.Var(&files, "file", "insert the contents of `PATH` (repeatable)")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "hex", "json"); err != nil {
+		return err
+	}
+
+	inputs := [][]byte{}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, data)
+	}
+	dataArgs := flags.Args()
+	if len(files) == 0 && len(dataArgs) == 0 {
+		dataArgs = []string{"-"}
+	}
+	for _, arg := range dataArgs {
+		data, err := c.readData(arg)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, data)
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	hashes := [][]byte{}
+	for _, data := range inputs {
+		// Nothing is saved unless every insert succeeds.
+		hash, err := tree.Insert(data)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := c.saveTree(tree); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := c.printHash(*format, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cli) runUpdate(args []string) error {
+	flags := c.flags("update")
+	format := flags.String("o", "hex", "output format: hex or json")
+	file := flags.String("file", "", "use the contents of `PATH` as the new data")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "hex", "json"); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 || (*file != "" && flags.NArg() != 1) {
+		return fmt.Errorf("%w: expected a hash and new data", errUsage)
+	}
+
+	hash, err := parseHash(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var data []byte
+	switch {
+	case *file != "":
+		data, err = os.ReadFile(*file)
+	case flags.NArg() == 2:
+		data, err = c.readData(flags.Arg(1))
+	default:
+		data, err = c.readData("-")
+	}
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	newHash, err := tree.Update(data, hash)
+	if err != nil {
+		return err
+	}
+	if err := c.saveTree(tree); err != nil {
+		return err
+	}
+
+	return c.printHash(*format, newHash)
+}
+
+func (c *cli) runGet(args []string) error {
+	flags := c.flags("get")
+	format := flags.String("o", "raw", "output format: raw, hex or json")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "raw", "hex", "json"); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: expected a single hash", errUsage)
+	}
+	hash, err := parseHash(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	node, err := tree.Lookup(hash)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return json.NewEncoder(c.stdout).Encode(map[string]string{
+			"hash": hex.EncodeToString(node.hash),
+			"data": hex.EncodeToString(node.data),
+		})
+	case "hex":
+		_, err = fmt.Fprintln(c.stdout, hex.EncodeToString(node.data))
+	default:
+		_, err = c.stdout.Write(node.data)
+	}
+	return err
+}
+
+func (c *cli) runDelete(args []string) error {
+	flags := c.flags("delete")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: expected a single hash", errUsage)
+	}
+	hash, err := parseHash(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	if err := tree.Delete(hash); err != nil {
+		return err
+	}
+
+	return c.saveTree(tree)
+}
+
+func (c *cli) runProve(args []string) error {
+	flags := c.flags("prove")
+	format := flags.String("o", "json", "output format: json or hex")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "json", "hex"); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: expected a single hash", errUsage)
+	}
+	hash, err := parseHash(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	if tree.root == nil {
+		return ErrHashNotFound
+	}
+	proof, err := tree.GenerateProof(hash)
+	if err != nil {
+		return err
+	}
+
+	if *format == "hex" {
+		for index, hash := range proof.ProofList {
+			side := "leaf"
+			if index > 0 && proof.Directions[index] {
+				side = "right"
+			} else if index > 0 {
+				side = "left"
+			}
+			fmt.Fprintf(c.stdout, "%s %s\n", side, hex.EncodeToString(hash))
+		}
+		return nil
+	}
+
+	encoded := proofJSON{
+		LeafHash:   hex.EncodeToString(proof.LeafHash),
+		ProofList:  []string{},
+		Directions: proof.Directions,
+		Sorted:     proof.Sorted,
+	}
+	for _, hash := range proof.ProofList {
+		encoded.ProofList = append(encoded.ProofList, hex.EncodeToString(hash))
+	}
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(encoded)
+}
+
+func (c *cli) runVerify(args []string) error {
+	flags := c.flags("verify")
+	rootArg := flags.String("root", "", "hex root hash to verify against; defaults to the tree file's root")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("%w: expected at most one proof file", errUsage)
+	}
+
+	var input []byte
+	var err error
+	if flags.NArg() == 0 || flags.Arg(0) == "-" {
+		input, err = io.ReadAll(c.stdin)
+	} else {
+		input, err = os.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	var root []byte
+	if *rootArg != "" {
+		if root, err = parseHash(*rootArg); err != nil {
+			return err
+		}
+	} else {
+		tree, err := c.loadTree()
+		if err != nil {
+			return err
+		}
+		root = tree.rootHash()
+	}
+
+	encoded := proofJSON{}
+	if err := json.NewDecoder(bytes.NewReader(input)).Decode(&encoded); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProof, err)
+	}
+	proof := &MerkelProof{Directions: encoded.Directions, Sorted: encoded.Sorted}
+	if proof.LeafHash, err = hex.DecodeString(encoded.LeafHash); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProof, err)
+	}
+	for _, piece := range encoded.ProofList {
+		hash, err := hex.DecodeString(piece)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidProof, err)
+		}
+		proof.ProofList = append(proof.ProofList, hash)
+	}
+
+	if len(root) == 0 || !VerifyProof(proof, root) {
+		fmt.Fprintln(c.stdout, "invalid")
+		return errInvalidProof
+	}
+	_, err = fmt.Fprintln(c.stdout, "valid")
+	return err
+}
+
+func (c *cli) runShow(args []string) error {
+	flags := c.flags("show")
+	format := flags.String("format", "text", "text, dot, mermaid, json or html")
+	showHashes := flags.Bool("hashes", false, "include hex hashes")
+	hashLength := flags.Int("hash-length", 0, "truncate hashes to `N` hex characters")
+	showDepth := flags.Bool("depth", false, "include node depths")
+	proveArg := flags.String("prove", "", "highlight the proof path of leaf `HASH`")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	formats := map[string]RenderFormat{
+		"text":    RenderText,
+		"dot":     RenderDOT,
+		"mermaid": RenderMermaid,
+		"json":    RenderJSON,
+	}
+	renderFormat, ok := formats[*format]
+	if !ok && *format != "html" {
+		return fmt.Errorf("%w: unknown format %q", errUsage, *format)
+	}
+
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	if *format == "html" {
+		return tree.ExportHTML(c.stdout)
+	}
+
+	opts := RenderOptions{
+		Format:     renderFormat,
+		ShowHash:   *showHashes,
+		HashLength: *hashLength,
+		ShowDepth:  *showDepth,
+	}
+	if *proveArg != "" {
+		hash, err := parseHash(*proveArg)
+		if err != nil {
+			return err
+		}
+		leaf, err := tree.Lookup(hash)
+		if err != nil {
+			return ErrHashNotFound
+		}
+		opts.Proof = tree.proofPath(leaf)
+	}
+
+	return tree.Render(c.stdout, opts)
+}
+
+func (c *cli) runRoot(args []string) error {
+	if err := parse(c.flags("root"), args); err != nil {
+		return err
+	}
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, hex.EncodeToString(tree.rootHash()))
+	return err
+}
+
+func (c *cli) runStats(args []string) error {
+	if err := parse(c.flags("stats"), args); err != nil {
+		return err
+	}
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(c.stdout, tree.Stats())
+	return err
+}
+
+func (c *cli) runServe(args []string) error {
+	flags := c.flags("serve")
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	tree, err := c.loadTree()
+	if err != nil {
+		return err
+	}
+
+	// Every change is written back to the tree file. Handlers run while the
+	// server holds its lock, so saves never overlap.
+	tree.OnRootChange(func(event TreeEvent) {
+		if err := c.saveTree(tree); err != nil {
+			fmt.Fprintf(c.stderr, "merkel: saving %s: %v\n", c.treePath, err)
+		}
+	})
+
+	fmt.Fprintf(c.stderr, "merkel: serving %s on %s\n", c.treePath, *addr)
+	return http.ListenAndServe(*addr, NewServer(tree))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runTestCLI runs a command against treePath and returns its exit code and
+// output.
+func runTestCLI(treePath, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := runCLI(append([]string{"-tree", treePath}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func Test_CLI(t *testing.T) {
+	treePath := filepath.Join(t.TempDir(), "test.tree")
+	hashA := hex.EncodeToString(Hash128([]byte("A")))
+
+	t.Run("Commands before init fail", func(t *testing.T) {
+		if code, _, _ := runTestCLI(treePath, "", "root"); code != exitError {
+			t.Errorf("Error: CLI: root without a tree file, Expected: %d, Actual: %d\n", exitError, code)
+		}
+	})
+
+	t.Run("Init and add", func(t *testing.T) {
+		if code, _, stderr := runTestCLI(treePath, "", "init"); code != exitOK {
+			t.Fatalf("Error: CLI: init: %d %s\n", code, stderr)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "init"); code != exitError {
+			t.Errorf("Error: CLI: init over an existing file should fail")
+		}
+
+		code, stdout, _ := runTestCLI(treePath, "", "add", "A", "B")
+		if code != exitOK || stdout != hashA+"\n"+hex.EncodeToString(Hash128([]byte("B")))+"\n" {
+			t.Errorf("Error: CLI: add: %d %q\n", code, stdout)
+		}
+
+		code, stdout, _ = runTestCLI(treePath, "from stdin", "add", "-o", "json")
+		if code != exitOK || !strings.Contains(stdout, hex.EncodeToString(Hash128([]byte("from stdin")))) {
+			t.Errorf("Error: CLI: add from stdin: %d %q\n", code, stdout)
+		}
+
+		file := filepath.Join(t.TempDir(), "data")
+		os.WriteFile(file, []byte("from a file"), 0o644)
+		if code, _, _ := runTestCLI(treePath, "", "add", "-file", file); code != exitOK {
+			t.Errorf("Error: CLI: add -file: %d\n", code)
+		}
+	})
+
+	t.Run("Duplicate add", func(t *testing.T) {
+		if code, _, _ := runTestCLI(treePath, "", "add", "C", "A"); code != exitDuplicate {
+			t.Errorf("Error: CLI: duplicate add, Expected: %d, Actual: %d\n", exitDuplicate, code)
+		}
+		// C must not have been saved either.
+		if code, _, _ := runTestCLI(treePath, "", "get", hex.EncodeToString(Hash128([]byte("C")))); code != exitNotFound {
+			t.Errorf("Error: CLI: failed add was partially saved")
+		}
+	})
+
+	t.Run("Update and get", func(t *testing.T) {
+		code, stdout, _ := runTestCLI(treePath, "", "update", hashA, "Z")
+		if code != exitOK || strings.TrimSpace(stdout) != hex.EncodeToString(Hash128([]byte("Z"))) {
+			t.Errorf("Error: CLI: update: %d %q\n", code, stdout)
+		}
+		code, stdout, _ = runTestCLI(treePath, "", "get", hashA)
+		if code != exitOK || stdout != "Z" {
+			t.Errorf("Error: CLI: get by old hash: %d %q\n", code, stdout)
+		}
+		code, stdout, _ = runTestCLI(treePath, "", "get", "-o", "hex", hashA)
+		if code != exitOK || stdout != "5a\n" {
+			t.Errorf("Error: CLI: get -o hex: %d %q\n", code, stdout)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "get", "00ff"); code != exitNotFound {
+			t.Errorf("Error: CLI: get missing hash, Expected: %d, Actual: %d\n", exitNotFound, code)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "get", "not-hex"); code != exitUsage {
+			t.Errorf("Error: CLI: get bad hash, Expected: %d, Actual: %d\n", exitUsage, code)
+		}
+	})
+
+	t.Run("Prove and verify", func(t *testing.T) {
+		code, proof, _ := runTestCLI(treePath, "", "prove", hashA)
+		if code != exitOK {
+			t.Fatalf("Error: CLI: prove: %d\n", code)
+		}
+		code, stdout, _ := runTestCLI(treePath, proof, "verify")
+		if code != exitOK || stdout != "valid\n" {
+			t.Errorf("Error: CLI: verify: %d %q\n", code, stdout)
+		}
+
+		_, root, _ := runTestCLI(treePath, "", "root")
+		code, _, _ = runTestCLI(treePath, proof, "verify", "-root", strings.TrimSpace(root))
+		if code != exitOK {
+			t.Errorf("Error: CLI: verify -root: %d\n", code)
+		}
+
+		code, _, _ = runTestCLI(treePath, proof, "verify", "-root", hashA)
+		if code != exitInvalidProof {
+			t.Errorf("Error: CLI: verify against the wrong root, Expected: %d, Actual: %d\n", exitInvalidProof, code)
+		}
+		code, _, _ = runTestCLI(treePath, "{", "verify")
+		if code != exitInvalidProof {
+			t.Errorf("Error: CLI: verify garbage, Expected: %d, Actual: %d\n", exitInvalidProof, code)
+		}
+	})
+
+	t.Run("Show, stats and delete", func(t *testing.T) {
+		code, stdout, _ := runTestCLI(treePath, "", "show", "-format", "dot")
+		if code != exitOK || !strings.HasPrefix(stdout, "digraph") {
+			t.Errorf("Error: CLI: show: %d %q\n", code, stdout)
+		}
+		code, stdout, _ = runTestCLI(treePath, "", "stats")
+		if code != exitOK || !strings.Contains(stdout, "leaves:           4") {
+			t.Errorf("Error: CLI: stats: %d %q\n", code, stdout)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "delete", hashA); code != exitOK {
+			t.Errorf("Error: CLI: delete: %d\n", code)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "delete", hashA); code != exitNotFound {
+			t.Errorf("Error: CLI: delete twice, Expected: %d, Actual: %d\n", exitNotFound, code)
+		}
+	})
+
+	t.Run("Usage errors", func(t *testing.T) {
+		if code, _, _ := runTestCLI(treePath, "", "bogus"); code != exitUsage {
+			t.Errorf("Error: CLI: unknown command, Expected: %d, Actual: %d\n", exitUsage, code)
+		}
+		if code, _, _ := runTestCLI(treePath, "", "show", "-format", "png"); code != exitUsage {
+			t.Errorf("Error: CLI: unknown format, Expected: %d, Actual: %d\n", exitUsage, code)
+		}
+	})
+}
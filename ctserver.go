@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxGetEntries caps how many entries a single get-entries call returns.
+// Clients page through the log by asking again from where they stopped.
+const MaxGetEntries = 256
+
+// LogServer serves a MerkelLog through endpoints shaped like Certificate
+// Transparency's (RFC 6962, section 4) so transparency log clients can
+// audit it. Entries are arbitrary bytes rather than certificate chains, but
+// hashes are RFC 6962's SHA-256 and field names and encodings (base64)
+// follow CT.
+//
+//	POST /ct/v1/add-entry            {"leaf_input"}   -> {"leaf_index", "leaf_hash", "timestamp"}
+//	GET  /ct/v1/get-sth                               -> {"tree_size", "timestamp", "sha256_root_hash", "tree_head_signature"}
+//	GET  /ct/v1/get-proof-by-hash?hash=&tree_size=    -> {"leaf_index", "audit_path"}
+//	GET  /ct/v1/get-sth-consistency?first=&second=    -> {"consistency"}
+//	GET  /ct/v1/get-entries?start=&end=               -> {"entries": [{"leaf_input"}]}
+type LogServer struct {
+	// MaxRequestBytes caps add-entry bodies; larger requests get a 413.
+	MaxRequestBytes int64
+	// Signer, when set, signs every tree head returned by get-sth. The
+	// signature is the one produced by SignLogRoot.
+	Signer ed25519.PrivateKey
+
+	mu  sync.RWMutex
+	log *MerkelLog
+	now func() time.Time
+	mux *http.ServeMux
+}
+
+// NewLogServer creates a transparency log server for log. The log must not
+// be modified other than through the server afterwards.
+func NewLogServer(merkelLog *MerkelLog) *LogServer {
+	server := &LogServer{
+		MaxRequestBytes: DefaultMaxRequestBytes,
+		log:             merkelLog,
+		now:             time.Now,
+		mux:             http.NewServeMux(),
+	}
+	server.mux.HandleFunc("POST /ct/v1/add-entry", server.handleAddEntry)
+	server.mux.HandleFunc("GET /ct/v1/get-sth", server.handleGetSTH)
+	server.mux.HandleFunc("GET /ct/v1/get-proof-by-hash", server.handleGetProofByHash)
+	server.mux.HandleFunc("GET /ct/v1/get-sth-consistency", server.handleGetConsistency)
+	server.mux.HandleFunc("GET /ct/v1/get-entries", server.handleGetEntries)
+
+	return server
+}
+
+func (server *LogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+type addEntryResponse struct {
+	LeafIndex uint64 `json:"leaf_index"`
+	LeafHash  []byte `json:"leaf_hash"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type getSTHResponse struct {
+	TreeSize      uint64 `json:"tree_size"`
+	Timestamp     int64  `json:"timestamp"`
+	RootHash      []byte `json:"sha256_root_hash"`
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+	Signature     []byte `json:"tree_head_signature,omitempty"`
+}
+
+type getProofByHashResponse struct {
+	LeafIndex uint64   `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+type getConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+type logEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+}
+
+type getEntriesResponse struct {
+	Entries []logEntry `json:"entries"`
+}
+
+// queryUint parses a required unsigned query parameter.
+func queryUint(r *http.Request, name string) (uint64, error) {
+	value, err := strconv.ParseUint(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid or missing %s", name)
+	}
+	return value, nil
+}
+
+// handleAddEntry appends an entry. Submitting an entry that's already in
+// the log returns the existing index instead of adding a duplicate.
+func (server *LogServer) handleAddEntry(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		LeafInput *[]byte `json:"leaf_input"`
+	}{}
+	if err := readBody(w, r, server.MaxRequestBytes, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.LeafInput == nil {
+		writeError(w, errors.New("leaf_input is required"))
+		return
+	}
+
+	server.mu.Lock()
+	hash := LogLeafHash(*body.LeafInput)
+	index, err := server.log.IndexOf(hash)
+	if err != nil {
+		index, _ = server.log.Append(*body.LeafInput)
+	}
+	server.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, addEntryResponse{
+		LeafIndex: index,
+		LeafHash:  hash,
+		Timestamp: server.now().UnixMilli(),
+	})
+}
+
+func (server *LogServer) handleGetSTH(w http.ResponseWriter, r *http.Request) {
+	server.mu.RLock()
+	size := server.log.Size()
+	root := server.log.RootHash()
+	server.mu.RUnlock()
+
+	response := getSTHResponse{
+		TreeSize:  size,
+		Timestamp: server.now().UnixMilli(),
+		RootHash:  root,
+	}
+	if server.Signer != nil {
+		sth, err := SignLogRoot(server.Signer, size, root, time.UnixMilli(response.Timestamp))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		response.HashAlgorithm = sth.HashAlgorithm
+		response.Signature = sth.Signature
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (server *LogServer) handleGetProofByHash(w http.ResponseWriter, r *http.Request) {
+	hash, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("hash"))
+	if err != nil || len(hash) == 0 {
+		writeError(w, errors.New("invalid or missing hash"))
+		return
+	}
+	size, err := queryUint(r, "tree_size")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if size > server.log.Size() {
+		writeError(w, fmt.Errorf("tree_size %d is beyond the log size %d", size, server.log.Size()))
+		return
+	}
+	index, err := server.log.IndexOf(hash)
+	if err == nil && index >= size {
+		err = fmt.Errorf("%w in a log of size %d", ErrHashNotFound, size)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := server.log.InclusionProof(index, size)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, getProofByHashResponse{LeafIndex: index, AuditPath: proof})
+}
+
+func (server *LogServer) handleGetConsistency(w http.ResponseWriter, r *http.Request) {
+	first, err := queryUint(r, "first")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	second, err := queryUint(r, "second")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	server.mu.RLock()
+	proof, err := server.log.ConsistencyProof(first, second)
+	server.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, getConsistencyResponse{Consistency: proof})
+}
+
+// handleGetEntries returns entries start to end inclusive, like CT. At most
+// MaxGetEntries are returned, and never more than the log holds.
+func (server *LogServer) handleGetEntries(w http.ResponseWriter, r *http.Request) {
+	start, err := queryUint(r, "start")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	end, err := queryUint(r, "end")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if end < start {
+		writeError(w, errors.New("end is before start"))
+		return
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if start >= server.log.Size() {
+		writeError(w, fmt.Errorf("start %d is beyond the log size %d", start, server.log.Size()))
+		return
+	}
+	end = min(end, start+MaxGetEntries-1, server.log.Size()-1)
+
+	response := getEntriesResponse{Entries: []logEntry{}}
+	for index := start; index <= end; index++ {
+		entry, _ := server.log.Entry(index)
+		response.Entries = append(response.Entries, logEntry{LeafInput: entry})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// ctClient is a minimal transparency log client auditing a LogServer.
+type ctClient struct {
+	t   *testing.T
+	url string
+}
+
+func (client *ctClient) get(path string, response any) int {
+	client.t.Helper()
+	result, err := http.Get(client.url + path)
+	if err != nil {
+		client.t.Fatalf("Error: LogServer: GET %s: %+v\n", path, err)
+	}
+	defer result.Body.Close()
+	json.NewDecoder(result.Body).Decode(response)
+	return result.StatusCode
+}
+
+func (client *ctClient) addEntry(data string) addEntryResponse {
+	client.t.Helper()
+	body := fmt.Sprintf(`{"leaf_input":%q}`, base64.StdEncoding.EncodeToString([]byte(data)))
+	result, err := http.Post(client.url+"/ct/v1/add-entry", "application/json", strings.NewReader(body))
+	if err != nil {
+		client.t.Fatalf("Error: LogServer: add-entry: %+v\n", err)
+	}
+	defer result.Body.Close()
+	response := addEntryResponse{}
+	json.NewDecoder(result.Body).Decode(&response)
+	return response
+}
+
+// signedTreeHead converts a get-sth response for VerifySignedTreeHead.
+func (response getSTHResponse) signedTreeHead() *SignedTreeHead {
+	return &SignedTreeHead{
+		TreeSize:      response.TreeSize,
+		RootHash:      response.RootHash,
+		Timestamp:     response.Timestamp,
+		HashAlgorithm: response.HashAlgorithm,
+		Signature:     response.Signature,
+	}
+}
+
+func Test_LogServer(t *testing.T) {
+	testServer := httptest.NewServer(NewLogServer(NewMerkelLog()))
+	defer testServer.Close()
+	client := &ctClient{t: t, url: testServer.URL}
+
+	for index := 0; index < 10; index++ {
+		client.addEntry(fmt.Sprintf("artifact %d", index))
+	}
+	oldSTH := getSTHResponse{}
+	client.get("/ct/v1/get-sth", &oldSTH)
+
+	t.Run("Add entry deduplicates", func(t *testing.T) {
+		first := client.addEntry("artifact 10")
+		again := client.addEntry("artifact 10")
+		if first.LeafIndex != 10 || again.LeafIndex != 10 {
+			t.Errorf("Error: LogServer: add-entry indexes, Expected: 10/10, Actual: %d/%d\n", first.LeafIndex, again.LeafIndex)
+		}
+		for index := 11; index < 27; index++ {
+			client.addEntry(fmt.Sprintf("artifact %d", index))
+		}
+	})
+
+	newSTH := getSTHResponse{}
+	client.get("/ct/v1/get-sth", &newSTH)
+
+	t.Run("STH", func(t *testing.T) {
+		if oldSTH.TreeSize != 10 || newSTH.TreeSize != 27 || newSTH.Timestamp == 0 {
+			t.Errorf("Error: LogServer: get-sth: %+v %+v\n", oldSTH, newSTH)
+		}
+	})
+
+	t.Run("Consistency between the two STHs", func(t *testing.T) {
+		response := getConsistencyResponse{}
+		status := client.get(fmt.Sprintf("/ct/v1/get-sth-consistency?first=%d&second=%d", oldSTH.TreeSize, newSTH.TreeSize), &response)
+		if status != http.StatusOK {
+			t.Fatalf("Error: LogServer: get-sth-consistency: %d\n", status)
+		}
+		if err := VerifyConsistency(oldSTH.TreeSize, newSTH.TreeSize, oldSTH.RootHash, newSTH.RootHash, response.Consistency); err != nil {
+			t.Errorf("Error: LogServer: consistency: %+v\n", err)
+		}
+	})
+
+	t.Run("Inclusion of an entry in both STHs", func(t *testing.T) {
+		hash := LogLeafHash([]byte("artifact 4"))
+		for _, sth := range []getSTHResponse{oldSTH, newSTH} {
+			response := getProofByHashResponse{}
+			query := url.Values{"hash": {base64.StdEncoding.EncodeToString(hash)}, "tree_size": {fmt.Sprint(sth.TreeSize)}}
+			if status := client.get("/ct/v1/get-proof-by-hash?"+query.Encode(), &response); status != http.StatusOK {
+				t.Fatalf("Error: LogServer: get-proof-by-hash: %d\n", status)
+			}
+			if err := VerifyInclusion(hash, response.LeafIndex, sth.TreeSize, response.AuditPath, sth.RootHash); err != nil {
+				t.Errorf("Error: LogServer: inclusion at size %d: %+v\n", sth.TreeSize, err)
+			}
+		}
+
+		// artifact 20 was added after the old STH.
+		query := url.Values{"hash": {base64.StdEncoding.EncodeToString(LogLeafHash([]byte("artifact 20")))}, "tree_size": {"10"}}
+		if status := client.get("/ct/v1/get-proof-by-hash?"+query.Encode(), &struct{}{}); status != http.StatusNotFound {
+			t.Errorf("Error: LogServer: proof for a later entry, Expected: 404, Actual: %d\n", status)
+		}
+	})
+
+	t.Run("Entries rebuild the root", func(t *testing.T) {
+		rebuilt := NewMerkelLog()
+		for start := uint64(0); start < newSTH.TreeSize; {
+			response := getEntriesResponse{}
+			client.get(fmt.Sprintf("/ct/v1/get-entries?start=%d&end=%d", start, start+7), &response)
+			if len(response.Entries) == 0 {
+				t.Fatalf("Error: LogServer: get-entries returned nothing at %d\n", start)
+			}
+			for _, entry := range response.Entries {
+				rebuilt.Append(entry.LeafInput)
+			}
+			start += uint64(len(response.Entries))
+		}
+		if string(rebuilt.RootHash()) != string(newSTH.RootHash) {
+			t.Errorf("Error: LogServer: entries don't rebuild the STH root")
+		}
+	})
+
+	t.Run("Bad requests", func(t *testing.T) {
+		for _, path := range []string{
+			"/ct/v1/get-sth-consistency?first=5&second=100",
+			"/ct/v1/get-sth-consistency?first=9&second=3",
+			"/ct/v1/get-entries?start=100&end=200",
+			"/ct/v1/get-entries?start=5&end=1",
+			"/ct/v1/get-entries?start=x&end=1",
+			"/ct/v1/get-proof-by-hash?hash=!!&tree_size=3",
+		} {
+			if status := client.get(path, &struct{}{}); status != http.StatusBadRequest {
+				t.Errorf("Error: LogServer: %s, Expected: 400, Actual: %d\n", path, status)
+			}
+		}
+	})
+}
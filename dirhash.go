@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// FileEntry describes a single file of a hashed directory along with the
+// proof that it belongs to the directory's root.
+type FileEntry struct {
+	Path   string       `json:"path"`
+	Digest []byte       `json:"digest"`
+	Proof  *MerkelProof `json:"proof"`
+}
+
+// DirectoryManifest is the result of hashing a directory. It's meant to be
+// stored (e.g. as JSON) next to a release so the release, or any single file
+// of it, can be verified later.
+type DirectoryManifest struct {
+	Root  []byte      `json:"root"`
+	Files []FileEntry `json:"files"`
+}
+
+// DirectoryDiff reports how a directory differs from a manifest.
+type DirectoryDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+	// RootMatches is true when the directory hashes to the manifest's root.
+	RootMatches bool
+}
+
+// Clean reports whether the directory matches the manifest exactly.
+func (diff *DirectoryDiff) Clean() bool {
+	return diff.RootMatches && len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Modified) == 0
+}
+
+// fileLeaf builds the leaf data for a file. The path and the SHA-256 of the
+// content are joined by a NUL byte, which can't appear in a path, so two
+// different files can never produce the same leaf.
+func fileLeaf(path string, digest []byte) []byte {
+	leaf := append([]byte(path), 0)
+	return append(leaf, digest...)
+}
+
+// digestFile streams a file through SHA-256.
+func digestFile(fsys fs.FS, path string) ([]byte, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// HashDirectory builds a merkel tree over every regular file in fsys and
+// returns its manifest. Files are inserted in sorted path order so the same
+// directory always produces the same root. Anything that isn't a regular
+// file (symlinks, devices, ...) is skipped.
+func HashDirectory(fsys fs.FS) (*DirectoryManifest, error) {
+	paths := []string{}
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir sorts per directory, which isn't the same as sorting the full
+	// paths ("a/b" comes before "a.txt"), so sort again.
+	sort.Strings(paths)
+
+	files := []FileEntry{}
+	for _, path := range paths {
+		digest, err := digestFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, FileEntry{Path: path, Digest: digest})
+	}
+
+	return buildManifest(files)
+}
+
+// buildManifest inserts the files into a new tree, in order, and fills in
+// their proofs.
+func buildManifest(files []FileEntry) (*DirectoryManifest, error) {
+	tree := InitMerkelTree()
+	for _, file := range files {
+		if _, err := tree.Insert(fileLeaf(file.Path, file.Digest)); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+
+	manifest := &DirectoryManifest{
+		Root:  tree.rootHash(),
+		Files: make([]FileEntry, 0, len(files)),
+	}
+	for _, file := range files {
+		proof, err := tree.GenerateProof(Hash128(fileLeaf(file.Path, file.Digest)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		file.Proof = proof
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	return manifest, nil
+}
+
+// VerifyDirectory hashes fsys and compares it against a stored manifest,
+// reporting every added, removed and modified file. The manifest's file list
+// is checked against its own root first so a tampered manifest can't hide a
+// change.
+func VerifyDirectory(fsys fs.FS, manifest *DirectoryManifest) (*DirectoryDiff, error) {
+	if manifest == nil {
+		return nil, errors.New("no manifest")
+	}
+	expected, err := buildManifest(manifest.Files)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(expected.Root, manifest.Root) {
+		return nil, errors.New("manifest files don't match the manifest root")
+	}
+
+	current, err := HashDirectory(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &DirectoryDiff{
+		RootMatches: bytes.Equal(current.Root, manifest.Root),
+	}
+	stored := map[string][]byte{}
+	for _, file := range manifest.Files {
+		stored[file.Path] = file.Digest
+	}
+	for _, file := range current.Files {
+		digest, ok := stored[file.Path]
+		if !ok {
+			diff.Added = append(diff.Added, file.Path)
+		} else if !bytes.Equal(digest, file.Digest) {
+			diff.Modified = append(diff.Modified, file.Path)
+		}
+		delete(stored, file.Path)
+	}
+	for path := range stored {
+		diff.Removed = append(diff.Removed, path)
+	}
+	sort.Strings(diff.Removed)
+
+	return diff, nil
+}
+
+// VerifyFile checks that the file at path in fsys, with its current
+// content, is the leaf proven by proof and that the proof rebuilds root.
+func VerifyFile(fsys fs.FS, path string, proof *MerkelProof, root []byte) error {
+	if proof == nil || len(proof.ProofList) == 0 {
+		return errors.New("no proof")
+	}
+	digest, err := digestFile(fsys, path)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(proof.ProofList[0], Hash128(fileLeaf(path, digest))) {
+		return fmt.Errorf("%s: content or path doesn't match the proof", path)
+	}
+	if !VerifyProof(proof, root) {
+		return fmt.Errorf("%s: proof doesn't match the root", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testRelease() fstest.MapFS {
+	return fstest.MapFS{
+		"README.md":      {Data: []byte("# release\n")},
+		"bin/merkel":     {Data: []byte("binary")},
+		"bin.txt":        {Data: []byte("not a directory")},
+		"docs/guide.txt": {Data: []byte("guide")},
+		"docs/empty.txt": {Data: []byte("")},
+	}
+}
+
+func Test_HashDirectory(t *testing.T) {
+	t.Run("Deterministic root and sorted files", func(t *testing.T) {
+		first, err := HashDirectory(testRelease())
+		if err != nil {
+			t.Fatalf("Error: HashDirectory: %+v\n", err)
+		}
+		second, _ := HashDirectory(testRelease())
+		if !bytes.Equal(first.Root, second.Root) {
+			t.Errorf("Error: HashDirectory: root isn't deterministic")
+		}
+
+		paths := []string{}
+		for _, file := range first.Files {
+			paths = append(paths, file.Path)
+		}
+		expected := "README.md,bin.txt,bin/merkel,docs/empty.txt,docs/guide.txt"
+		if strings.Join(paths, ",") != expected {
+			t.Errorf("Error: HashDirectory: Expected: %s, Actual: %s\n", expected, strings.Join(paths, ","))
+		}
+	})
+
+	t.Run("Every file proof verifies", func(t *testing.T) {
+		release := testRelease()
+		manifest, _ := HashDirectory(release)
+		for _, file := range manifest.Files {
+			if err := VerifyFile(release, file.Path, file.Proof, manifest.Root); err != nil {
+				t.Errorf("Error: VerifyFile: %+v\n", err)
+			}
+		}
+
+		release["bin/merkel"] = &fstest.MapFile{Data: []byte("trojan")}
+		if err := VerifyFile(release, "bin/merkel", manifest.Files[2].Proof, manifest.Root); err == nil {
+			t.Errorf("Error: VerifyFile: modified file verified")
+		}
+		// A valid proof for another file doesn't prove this one.
+		if err := VerifyFile(release, "README.md", manifest.Files[3].Proof, manifest.Root); err == nil {
+			t.Errorf("Error: VerifyFile: proof of another file accepted")
+		}
+	})
+
+	t.Run("Directory changes are reported", func(t *testing.T) {
+		manifest, _ := HashDirectory(testRelease())
+
+		// The manifest survives a JSON round trip.
+		encoded, _ := json.Marshal(manifest)
+		stored := &DirectoryManifest{}
+		if err := json.Unmarshal(encoded, stored); err != nil {
+			t.Fatalf("Error: HashDirectory: manifest JSON: %+v\n", err)
+		}
+
+		diff, err := VerifyDirectory(testRelease(), stored)
+		if err != nil || !diff.Clean() {
+			t.Fatalf("Error: VerifyDirectory: unchanged directory reported dirty: %+v %+v\n", diff, err)
+		}
+
+		release := testRelease()
+		release["docs/guide.txt"] = &fstest.MapFile{Data: []byte("new guide")}
+		release["docs/extra.txt"] = &fstest.MapFile{Data: []byte("extra")}
+		delete(release, "bin/merkel")
+		diff, err = VerifyDirectory(release, stored)
+		if err != nil {
+			t.Fatalf("Error: VerifyDirectory: %+v\n", err)
+		}
+		if diff.Clean() || diff.RootMatches {
+			t.Errorf("Error: VerifyDirectory: changed directory reported clean")
+		}
+		if strings.Join(diff.Added, ",") != "docs/extra.txt" ||
+			strings.Join(diff.Removed, ",") != "bin/merkel" ||
+			strings.Join(diff.Modified, ",") != "docs/guide.txt" {
+			t.Errorf("Error: VerifyDirectory: unexpected diff %+v\n", diff)
+		}
+	})
+
+	t.Run("Tampered manifest", func(t *testing.T) {
+		manifest, _ := HashDirectory(testRelease())
+		manifest.Files = manifest.Files[1:]
+		if _, err := VerifyDirectory(testRelease(), manifest); err == nil {
+			t.Errorf("Error: VerifyDirectory: tampered manifest accepted")
+		}
+	})
+
+	t.Run("Empty directory", func(t *testing.T) {
+		manifest, err := HashDirectory(fstest.MapFS{})
+		if err != nil || manifest.Root != nil || len(manifest.Files) != 0 {
+			t.Errorf("Error: HashDirectory: empty directory: %+v %+v\n", manifest, err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import "sync"
+
+// EventKind identifies which mutation a TreeEvent describes.
+type EventKind int
+
+const (
+	EventInsert EventKind = iota
+	EventUpdate
+	EventDelete
+	EventRootChange
+)
+
+// TreeEvent describes a single change made to the tree.
+//   - OldHash is nil for inserts and NewHash is nil for deletes.
+//   - OldRoot is nil when the tree was empty and NewRoot is nil once the
+//     last leaf has been deleted.
+type TreeEvent struct {
+	Kind    EventKind
+	OldHash []byte
+	NewHash []byte
+	OldRoot []byte
+	NewRoot []byte
+	// Dropped is only set on events received through SubscribeDropping: the
+	// number of events dropped just before this one because the channel was
+	// full.
+	Dropped int
+}
+
+// EventHandler receives tree events.
+type EventHandler func(event TreeEvent)
+
+type subscription struct {
+	id      int
+	kind    EventKind
+	handler EventHandler
+}
+
+// eventHooks holds the subscriptions of a single tree. The zero value has no
+// subscribers.
+type eventHooks struct {
+	nextID        int
+	subscriptions []subscription
+}
+
+// OnInsert calls handler after every successful Insert.
+//
+// Ordering guarantees for every On* handler and Subscribe channel:
+//  1. Handlers run synchronously once the mutation has been fully applied,
+//     so the tree, root hash and lookups already reflect the change.
+//  2. Handlers run before the mutating call returns, in the order they
+//     were registered.
+//  3. The Insert/Update/Delete event is delivered before the root change
+//     event of the same mutation.
+//
+// The returned function removes the handler.
+func (merkelTree *MerkelTree) OnInsert(handler EventHandler) func() {
+	return merkelTree.subscribe(EventInsert, handler)
+}
+
+// OnUpdate calls handler after every successful Update.
+func (merkelTree *MerkelTree) OnUpdate(handler EventHandler) func() {
+	return merkelTree.subscribe(EventUpdate, handler)
+}
+
+// OnDelete calls handler after every successful Delete.
+func (merkelTree *MerkelTree) OnDelete(handler EventHandler) func() {
+	return merkelTree.subscribe(EventDelete, handler)
+}
+
+// OnRootChange calls handler every time a mutation changes the root hash.
+func (merkelTree *MerkelTree) OnRootChange(handler EventHandler) func() {
+	return merkelTree.subscribe(EventRootChange, handler)
+}
+
+// Subscribe delivers every event of the given kind through a channel with
+// the requested buffer size instead of a callback, in mutation order. Once
+// the buffer is full the mutating call waits for the reader, so read from
+// another goroutine or use SubscribeDropping.
+//
+// The returned function unsubscribes and closes the channel. Like every
+// other change to the tree it must not run during a mutation; calling it
+// more than once is harmless.
+func (merkelTree *MerkelTree) Subscribe(kind EventKind, buffer int) (<-chan TreeEvent, func()) {
+	events := make(chan TreeEvent, buffer)
+	unsubscribe := merkelTree.subscribe(kind, func(event TreeEvent) {
+		events <- event
+	})
+
+	return events, closer(unsubscribe, events)
+}
+
+// SubscribeDropping is Subscribe for readers that must never hold up the
+// tree: when the buffer is full the event is dropped and counted in the
+// Dropped field of the next event that fits.
+func (merkelTree *MerkelTree) SubscribeDropping(kind EventKind, buffer int) (<-chan TreeEvent, func()) {
+	events := make(chan TreeEvent, buffer)
+	dropped := 0
+	unsubscribe := merkelTree.subscribe(kind, func(event TreeEvent) {
+		event.Dropped = dropped
+		select {
+		case events <- event:
+			dropped = 0
+		default:
+			dropped++
+		}
+	})
+
+	return events, closer(unsubscribe, events)
+}
+
+// closer unsubscribes and closes events exactly once.
+func closer(unsubscribe func(), events chan TreeEvent) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unsubscribe()
+			close(events)
+		})
+	}
+}
+
+// subscribe registers handler and returns a function that removes it again.
+// Calling the returned function more than once is harmless.
+func (merkelTree *MerkelTree) subscribe(kind EventKind, handler EventHandler) func() {
+	hooks := &merkelTree.hooks
+	id := hooks.nextID
+	hooks.nextID++
+	hooks.subscriptions = append(hooks.subscriptions, subscription{
+		id:      id,
+		kind:    kind,
+		handler: handler,
+	})
+
+	return func() {
+		for index, sub := range hooks.subscriptions {
+			if sub.id == id {
+				hooks.subscriptions = append(hooks.subscriptions[:index:index], hooks.subscriptions[index+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit delivers event to its subscribers followed by a root change event
+// when the root hash moved.
+func (merkelTree *MerkelTree) emit(event TreeEvent) {
+	merkelTree.deliver(event)
+
+	if string(event.OldRoot) != string(event.NewRoot) {
+		rootEvent := event
+		rootEvent.Kind = EventRootChange
+		merkelTree.deliver(rootEvent)
+	}
+}
+
+func (merkelTree *MerkelTree) deliver(event TreeEvent) {
+	// Iterate over a copy so handlers can unsubscribe while being called.
+	subscriptions := append([]subscription{}, merkelTree.hooks.subscriptions...)
+	for _, sub := range subscriptions {
+		if sub.kind == event.Kind {
+			sub.handler(event)
+		}
+	}
+}
+
+// rootHash returns a copy of the current root hash, nil on an empty tree.
+func (merkelTree *MerkelTree) rootHash() []byte {
+	if merkelTree.root == nil {
+		return nil
+	}
+
+	return append([]byte{}, merkelTree.commitment(merkelTree.root)...)
+}
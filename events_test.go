@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_Events(t *testing.T) {
+	t.Run("Handlers receive hashes and roots in order", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		order := []EventKind{}
+		var lastInsert, lastUpdate, lastDelete, lastRoot TreeEvent
+
+		testMerkelTree.OnInsert(func(event TreeEvent) {
+			order = append(order, event.Kind)
+			lastInsert = event
+			// The mutation must already be visible.
+			if _, err := testMerkelTree.Lookup(event.NewHash); err != nil {
+				t.Errorf("Error: OnInsert: inserted hash not visible yet")
+			}
+		})
+		testMerkelTree.OnUpdate(func(event TreeEvent) {
+			order = append(order, event.Kind)
+			lastUpdate = event
+		})
+		testMerkelTree.OnDelete(func(event TreeEvent) {
+			order = append(order, event.Kind)
+			lastDelete = event
+		})
+		testMerkelTree.OnRootChange(func(event TreeEvent) {
+			order = append(order, event.Kind)
+			lastRoot = event
+			if string(event.NewRoot) != string(testMerkelTree.rootHash()) {
+				t.Errorf("Error: OnRootChange: root mismatch")
+			}
+		})
+
+		hashA, _ := testMerkelTree.Insert([]byte("A"))
+		if lastInsert.OldRoot != nil || string(lastInsert.NewHash) != string(hashA) {
+			t.Errorf("Error: OnInsert: unexpected event %+v\n", lastInsert)
+		}
+		testMerkelTree.Insert([]byte("B"))
+		rootAB := testMerkelTree.rootHash()
+
+		hashC, _ := testMerkelTree.Update([]byte("C"), hashA)
+		if string(lastUpdate.OldHash) != string(hashA) || string(lastUpdate.NewHash) != string(hashC) {
+			t.Errorf("Error: OnUpdate: unexpected hashes %+v\n", lastUpdate)
+		}
+		if string(lastRoot.OldRoot) != string(rootAB) {
+			t.Errorf("Error: OnRootChange: old root mismatch")
+		}
+
+		testMerkelTree.Delete(hashC)
+		if string(lastDelete.OldHash) != string(hashC) || lastDelete.NewHash != nil {
+			t.Errorf("Error: OnDelete: unexpected hashes %+v\n", lastDelete)
+		}
+
+		// A failed mutation emits nothing.
+		testMerkelTree.Insert([]byte("B"))
+
+		expected := []EventKind{
+			EventInsert, EventRootChange,
+			EventInsert, EventRootChange,
+			EventUpdate, EventRootChange,
+			EventDelete, EventRootChange,
+		}
+		if len(order) != len(expected) {
+			t.Fatalf("Error: Events: Expected %v, Actual: %v\n", expected, order)
+		}
+		for index := range expected {
+			if order[index] != expected[index] {
+				t.Errorf("Error: Events: Expected %v, Actual: %v\n", expected, order)
+				break
+			}
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		calls := 0
+		unsubscribe := testMerkelTree.OnInsert(func(event TreeEvent) { calls++ })
+		testMerkelTree.Insert([]byte("A"))
+		unsubscribe()
+		unsubscribe()
+		testMerkelTree.Insert([]byte("B"))
+		if calls != 1 {
+			t.Errorf("Error: Unsubscribe: Expected 1 call, Actual: %d\n", calls)
+		}
+	})
+
+	t.Run("Channel subscription", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		events, unsubscribe := testMerkelTree.Subscribe(EventRootChange, 3)
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		testMerkelTree.Insert([]byte("C"))
+		unsubscribe()
+
+		var previous []byte
+		count := 0
+		for event := range events {
+			if string(event.OldRoot) != string(previous) {
+				t.Errorf("Error: Subscribe: events out of order")
+			}
+			previous = event.NewRoot
+			count++
+		}
+		if count != 3 {
+			t.Errorf("Error: Subscribe: Expected 3 events, Actual: %d\n", count)
+		}
+		if string(previous) != string(testMerkelTree.rootHash()) {
+			t.Errorf("Error: Subscribe: last root mismatch")
+		}
+	})
+	t.Run("Full channel waits for the reader", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		events, unsubscribe := testMerkelTree.Subscribe(EventInsert, 0)
+
+		received := make(chan []string)
+		go func() {
+			seen := []string{}
+			for event := range events {
+				seen = append(seen, string(event.NewHash))
+			}
+			received <- seen
+		}()
+
+		expected := []string{}
+		for index := 0; index < 50; index++ {
+			hash, _ := testMerkelTree.Insert([]byte(fmt.Sprintf("leaf %d", index)))
+			expected = append(expected, string(hash))
+		}
+		unsubscribe()
+		unsubscribe()
+
+		if seen := <-received; fmt.Sprint(seen) != fmt.Sprint(expected) {
+			t.Errorf("Error: Subscribe: Expected all %d events in order, Actual: %d\n", len(expected), len(seen))
+		}
+	})
+
+	t.Run("Full channel drops events when asked to", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		events, unsubscribe := testMerkelTree.SubscribeDropping(EventInsert, 1)
+		defer unsubscribe()
+
+		// Nobody reads while inserting, which would block forever if the
+		// tree waited for the channel.
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		testMerkelTree.Insert([]byte("C"))
+
+		first := <-events
+		if !compareHash(first.NewHash, Hash128([]byte("A"))) || first.Dropped != 0 {
+			t.Errorf("Error: SubscribeDropping: Expected: A with 0 dropped, Actual: %x with %d dropped\n", first.NewHash, first.Dropped)
+		}
+		testMerkelTree.Insert([]byte("D"))
+		next := <-events
+		if !compareHash(next.NewHash, Hash128([]byte("D"))) || next.Dropped != 2 {
+			t.Errorf("Error: SubscribeDropping: Expected: D with 2 dropped, Actual: %x with %d dropped\n", next.NewHash, next.Dropped)
+		}
+	})
+}
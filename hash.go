@@ -2,6 +2,9 @@ package main
 
 import "crypto/sha256"
 
+// hashSize is the length of a Hash128.
+const hashSize = 16
+
 // Hash128 implements SHA-256 encryption on incoming data.
 func Hash128(data []byte) []byte {
 	hash := sha256.Sum256(data)
@@ -12,6 +15,9 @@ func Hash128(data []byte) []byte {
 // they are equivalent. This is done for explicit comparison over
 // string(hash1) == string(hash2)
 func compareHash(hash1, hash2 []byte) bool {
+	if len(hash1) != len(hash2) {
+		return false
+	}
 	for index, data1 := range hash1 {
 		if data1 != hash2[index] {
 			return false
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// htmlNode is a node as it's laid out in the HTML explorer.
+type htmlNode struct {
+	ID    string
+	Data  string
+	Hash  string
+	Leaf  bool
+	Left  *htmlNode
+	Right *htmlNode
+}
+
+// htmlProof is what the explorer shows once a leaf is clicked.
+type htmlProof struct {
+	Path       []string `json:"path"`
+	ProofList  []string `json:"proofList"`
+	Directions []bool   `json:"directions"`
+}
+
+// htmlPage holds everything the explorer template needs.
+type htmlPage struct {
+	Root     *htmlNode
+	RootHash string
+	Leaves   int
+	Proofs   map[string]htmlProof
+}
+
+// ExportHTML writes a single, self-contained HTML page for exploring the
+// tree. Every node can be collapsed, and clicking a leaf highlights the path
+// its proof walks up to root. Styles and scripts are inlined so the
+// file works offline.
+func (merkelTree *MerkelTree) ExportHTML(w io.Writer) error {
+	page := &htmlPage{
+		Proofs: map[string]htmlProof{},
+	}
+
+	ids := map[*Node]string{}
+	page.Root = merkelTree.htmlTree(merkelTree.root, ids)
+	if merkelTree.root != nil {
+		page.RootHash = hex.EncodeToString(merkelTree.rootHash())
+	}
+
+	for _, leafDepth := range merkelTree.navigateTree() {
+		leaf := leafDepth.node
+		proof := merkelTree.proofPath(leaf)
+		entry := htmlProof{Directions: proof.Directions}
+		for node := leaf; node != nil; node = node.prev {
+			entry.Path = append(entry.Path, ids[node])
+		}
+		for _, hash := range proof.ProofList {
+			entry.ProofList = append(entry.ProofList, hex.EncodeToString(hash))
+		}
+		page.Proofs[ids[leaf]] = entry
+		page.Leaves++
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
+
+// htmlTree assigns every node an element id and converts it for the template.
+func (merkelTree *MerkelTree) htmlTree(node *Node, ids map[*Node]string) *htmlNode {
+	if node == nil {
+		return nil
+	}
+
+	id := fmt.Sprintf("node-%d", len(ids))
+	ids[node] = id
+
+	return &htmlNode{
+		ID:    id,
+		Data:  string(node.data),
+		Hash:  hex.EncodeToString(node.hash),
+		Leaf:  node.left == nil && node.right == nil,
+		Left:  merkelTree.htmlTree(node.left, ids),
+		Right: merkelTree.htmlTree(node.right, ids),
+	}
+}
+
+var htmlTemplate = template.Must(template.New("explorer").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Merkel Tree Explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+code, .hash { font-family: monospace; word-break: break-all; }
+ul { list-style: none; padding-left: 1.5em; border-left: 1px dashed #bbb; }
+details > summary { cursor: pointer; }
+.node { display: inline-block; padding: 2px 6px; border-radius: 4px; }
+.leaf { cursor: pointer; background: #e7f5ff; }
+.leaf:hover { background: #d0ebff; }
+.on-path { background: #ffd27f !important; outline: 2px solid #d9480f; }
+.data { font-weight: bold; }
+.hash { color: #666; font-size: 0.85em; }
+#proof { margin-top: 2em; padding: 1em; border: 1px solid #ccc; border-radius: 4px; }
+#proof ol { padding-left: 1.5em; }
+</style>
+</head>
+<body>
+<h1>Merkel Tree Explorer</h1>
+<p>Root hash: <code>{{if .RootHash}}{{.RootHash}}{{else}}(empty tree){{end}}</code><br>Leaves: {{.Leaves}}</p>
+<p>Click a leaf to highlight the path its proof takes up to root.</p>
+{{define "node"}}<li>{{if .Leaf}}<span class="node leaf" id="{{.ID}}" data-leaf="{{.ID}}"><span class="data">{{.Data}}</span> <span class="hash">{{.Hash}}</span></span>{{else}}<details open><summary><span class="node" id="{{.ID}}"><span class="data">{{.Data}}</span> <span class="hash">{{.Hash}}</span></span></summary><ul>{{if .Left}}{{template "node" .Left}}{{end}}{{if .Right}}{{template "node" .Right}}{{end}}</ul></details>{{end}}</li>{{end}}
+{{if .Root}}<ul id="tree">{{template "node" .Root}}</ul>{{end}}
+<div id="proof" hidden>
+<h2>Proof</h2>
+<ol id="proof-list"></ol>
+</div>
+<script>
+const proofs = {{.Proofs}};
+document.querySelectorAll("[data-leaf]").forEach(function (leaf) {
+  leaf.addEventListener("click", function () {
+    document.querySelectorAll(".on-path").forEach(function (el) { el.classList.remove("on-path"); });
+    const proof = proofs[leaf.dataset.leaf];
+    proof.path.forEach(function (id) {
+      const el = document.getElementById(id);
+      el.classList.add("on-path");
+      const details = el.closest("details");
+      if (details) { details.open = true; }
+    });
+    const list = document.getElementById("proof-list");
+    list.replaceChildren();
+    proof.proofList.forEach(function (hash, index) {
+      const item = document.createElement("li");
+      const side = index === 0 ? "leaf" : (proof.directions[index] ? "right sibling" : "left sibling");
+      item.textContent = side + ": " + hash;
+      item.className = "hash";
+      list.appendChild(item);
+    });
+    document.getElementById("proof").hidden = false;
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func Test_ExportHTML(t *testing.T) {
+	t.Run("Export a 4 leaf tree", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		testMerkelTree.Insert([]byte("C"))
+		testMerkelTree.Insert([]byte("<D>"))
+
+		var out bytes.Buffer
+		if err := testMerkelTree.ExportHTML(&out); err != nil {
+			t.Fatalf("Error: ExportHTML: %+v\n", err)
+		}
+		page := out.String()
+
+		if !strings.Contains(page, hex.EncodeToString(testMerkelTree.root.hash)) {
+			t.Errorf("Error: ExportHTML: root hash missing")
+		}
+		if strings.Count(page, "data-leaf=") != 4 {
+			t.Errorf("Error: ExportHTML: expected 4 clickable leaves")
+		}
+		if strings.Contains(page, "<D>") || !strings.Contains(page, "&lt;D&gt;") {
+			t.Errorf("Error: ExportHTML: leaf data not escaped")
+		}
+		if strings.Contains(page, "src=") || strings.Contains(page, "href=") {
+			t.Errorf("Error: ExportHTML: page references external assets")
+		}
+		if !strings.Contains(page, `"path":["node-2","node-1","node-0"]`) {
+			t.Errorf("Error: ExportHTML: proof path for the first leaf missing")
+		}
+	})
+
+	t.Run("Export an empty tree", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := InitMerkelTree().ExportHTML(&out); err != nil {
+			t.Fatalf("Error: ExportHTML: %+v\n", err)
+		}
+		if !strings.Contains(out.String(), "(empty tree)") {
+			t.Errorf("Error: ExportHTML: empty tree not reported")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+)
+
+// KaryTree is a Merkle tree whose nodes have up to Arity children instead
+// of two, which trades wider proof levels for fewer of them. Leaves stay in
+// insertion order and are grouped Arity at a time on every level; a node
+// covering a single child is that child, so short right edges don't add
+// levels.
+//
+//	leaf hash = LogLeafHash(data)
+//	node hash = SHA-256(0x01 || child0 || child1 || ...)
+type KaryTree struct {
+	arity int
+	// levels[0] holds the leaf hashes and levels[k] the nodes grouping
+	// levels[k-1]. The last level holds the root.
+	levels [][][]byte
+	lookup map[string]int
+}
+
+// KaryProofLevel is one level of a KaryProof: the other children of the
+// node being climbed through and where the proven hash sits among them.
+type KaryProofLevel struct {
+	Position int
+	Siblings [][]byte
+}
+
+// KaryProof proves a leaf is part of a KaryTree.
+type KaryProof struct {
+	LeafHash []byte
+	Levels   []KaryProofLevel
+}
+
+// NewKaryTree creates an empty tree where nodes have up to arity children.
+func NewKaryTree(arity int) (*KaryTree, error) {
+	if arity < 2 || arity > 256 {
+		return nil, fmt.Errorf("invalid arity %d", arity)
+	}
+	return &KaryTree{
+		arity:  arity,
+		levels: [][][]byte{{}},
+		lookup: map[string]int{},
+	}, nil
+}
+
+// Arity returns the maximum number of children of a node.
+func (tree *KaryTree) Arity() int {
+	return tree.arity
+}
+
+// Len returns the number of leaves.
+func (tree *KaryTree) Len() int {
+	return len(tree.levels[0])
+}
+
+// karyNodeHash hashes a group of children. A single child is passed up as is.
+func karyNodeHash(children [][]byte) []byte {
+	if len(children) == 1 {
+		return children[0]
+	}
+	joined := []byte{0x01}
+	for _, child := range children {
+		joined = append(joined, child...)
+	}
+	return logHash(joined)
+}
+
+// Insert appends data as a new leaf and rehashes the right edge of the
+// tree. Inserting the same data twice returns ErrHashExists.
+func (tree *KaryTree) Insert(data []byte) error {
+	hash := LogLeafHash(data)
+	if _, ok := tree.lookup[string(hash)]; ok {
+		return ErrHashExists
+	}
+	tree.lookup[string(hash)] = len(tree.levels[0])
+	tree.levels[0] = append(tree.levels[0], hash)
+
+	for level := 0; len(tree.levels[level]) > 1; level++ {
+		if level+1 == len(tree.levels) {
+			tree.levels = append(tree.levels, [][]byte{})
+		}
+		group := (len(tree.levels[level]) - 1) / tree.arity
+		start := group * tree.arity
+		node := karyNodeHash(tree.levels[level][start:])
+		if group == len(tree.levels[level+1]) {
+			tree.levels[level+1] = append(tree.levels[level+1], node)
+		} else {
+			tree.levels[level+1][group] = node
+		}
+	}
+
+	return nil
+}
+
+// RootHash returns the root hash, nil for an empty tree.
+func (tree *KaryTree) RootHash() []byte {
+	top := tree.levels[len(tree.levels)-1]
+	if len(top) == 0 {
+		return nil
+	}
+	return top[0]
+}
+
+// GenerateProof returns the proof for the leaf with hash leafHash, i.e.
+// LogLeafHash of the inserted data.
+func (tree *KaryTree) GenerateProof(leafHash []byte) (*KaryProof, error) {
+	index, ok := tree.lookup[string(leafHash)]
+	if !ok {
+		return nil, ErrHashNotFound
+	}
+
+	proof := &KaryProof{LeafHash: leafHash, Levels: []KaryProofLevel{}}
+	for level := 0; len(tree.levels[level]) > 1; level++ {
+		start := index / tree.arity * tree.arity
+		end := min(start+tree.arity, len(tree.levels[level]))
+		siblings := [][]byte{}
+		siblings = append(siblings, tree.levels[level][start:index]...)
+		siblings = append(siblings, tree.levels[level][index+1:end]...)
+		proof.Levels = append(proof.Levels, KaryProofLevel{Position: index - start, Siblings: siblings})
+		index /= tree.arity
+	}
+
+	return proof, nil
+}
+
+// VerifyKaryProof rebuilds the root from the proof and compares it with
+// rootHash.
+func VerifyKaryProof(proof *KaryProof, rootHash []byte) bool {
+	if proof == nil {
+		return false
+	}
+
+	hash := proof.LeafHash
+	for _, level := range proof.Levels {
+		if level.Position < 0 || level.Position > len(level.Siblings) || len(level.Siblings) >= 256 {
+			return false
+		}
+		for _, sibling := range level.Siblings {
+			if len(sibling) != len(proof.LeafHash) {
+				return false
+			}
+		}
+		children := [][]byte{}
+		children = append(children, level.Siblings[:level.Position]...)
+		children = append(children, hash)
+		children = append(children, level.Siblings[level.Position:]...)
+		hash = karyNodeHash(children)
+	}
+
+	return compareHash(hash, rootHash)
+}
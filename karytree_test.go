@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_KaryTree(t *testing.T) {
+	t.Run("Proofs for every leaf and arity", func(t *testing.T) {
+		for _, arity := range []int{2, 3, 4, 8, 16} {
+			tree, err := NewKaryTree(arity)
+			if err != nil {
+				t.Fatalf("Error: NewKaryTree: %+v\n", err)
+			}
+			for index := 0; index < 70; index++ {
+				tree.Insert([]byte(fmt.Sprintf("leaf %d", index)))
+				for leaf := 0; leaf <= index; leaf++ {
+					proof, err := tree.GenerateProof(LogLeafHash([]byte(fmt.Sprintf("leaf %d", leaf))))
+					if err != nil || !VerifyKaryProof(proof, tree.RootHash()) {
+						t.Fatalf("Error: VerifyKaryProof: arity %d leaf %d of %d: %v\n", arity, leaf, index+1, err)
+					}
+					for _, level := range proof.Levels {
+						if len(level.Siblings) >= arity {
+							t.Fatalf("Error: GenerateProof: arity %d level with %d siblings\n", arity, len(level.Siblings))
+						}
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Root matches a tree built from scratch", func(t *testing.T) {
+		tree, _ := NewKaryTree(4)
+		for index := 0; index < 5; index++ {
+			tree.Insert([]byte(fmt.Sprintf("leaf %d", index)))
+		}
+		leaves := [][]byte{}
+		for index := 0; index < 5; index++ {
+			leaves = append(leaves, LogLeafHash([]byte(fmt.Sprintf("leaf %d", index))))
+		}
+		// The fifth leaf is alone in its group and gets passed up as is.
+		expected := karyNodeHash([][]byte{karyNodeHash(leaves[:4]), leaves[4]})
+		if !bytes.Equal(tree.RootHash(), expected) {
+			t.Errorf("Error: RootHash: Expected: %x, Actual: %x\n", expected, tree.RootHash())
+		}
+		proof, _ := tree.GenerateProof(leaves[4])
+		if len(proof.Levels) != 2 || len(proof.Levels[0].Siblings) != 0 {
+			t.Errorf("Error: GenerateProof: unexpected proof shape %+v\n", proof.Levels)
+		}
+	})
+
+	t.Run("Errors and forged proofs", func(t *testing.T) {
+		tree, _ := NewKaryTree(8)
+		for index := 0; index < 20; index++ {
+			tree.Insert([]byte(fmt.Sprintf("leaf %d", index)))
+		}
+		if err := tree.Insert([]byte("leaf 3")); !errors.Is(err, ErrHashExists) {
+			t.Errorf("Error: Insert: Expected: %v, Actual: %v\n", ErrHashExists, err)
+		}
+		if _, err := tree.GenerateProof(LogLeafHash([]byte("missing"))); !errors.Is(err, ErrHashNotFound) {
+			t.Errorf("Error: GenerateProof: Expected: %v, Actual: %v\n", ErrHashNotFound, err)
+		}
+		if _, err := NewKaryTree(1); err == nil {
+			t.Errorf("Error: NewKaryTree: arity 1 accepted")
+		}
+
+		proof, _ := tree.GenerateProof(LogLeafHash([]byte("leaf 10")))
+		proof.Levels[0].Position++
+		if VerifyKaryProof(proof, tree.RootHash()) {
+			t.Errorf("Error: VerifyKaryProof: wrong position accepted")
+		}
+		proof.Levels[0].Position = len(proof.Levels[0].Siblings) + 1
+		if VerifyKaryProof(proof, tree.RootHash()) {
+			t.Errorf("Error: VerifyKaryProof: out of range position accepted")
+		}
+	})
+}
+
+func benchmarkLeaves(count int) [][]byte {
+	leaves := [][]byte{}
+	for index := 0; index < count; index++ {
+		leaves = append(leaves, []byte(fmt.Sprintf("leaf %d", index)))
+	}
+	return leaves
+}
+
+// The build benchmarks insert 4096 leaves; the proof size metric is the
+// total length of the hashes in the proof of the last leaf, leaf included.
+
+func Benchmark_MerkelTreeBuild(b *testing.B) {
+	leaves := benchmarkLeaves(4096)
+	var tree *MerkelTree
+	for run := 0; run < b.N; run++ {
+		tree = InitMerkelTree()
+		for _, leaf := range leaves {
+			tree.Insert(leaf)
+		}
+	}
+	proof, _ := tree.GenerateProof(Hash128(leaves[len(leaves)-1]))
+	proofBytes := 0
+	for _, hash := range proof.ProofList {
+		proofBytes += len(hash)
+	}
+	b.ReportMetric(float64(proofBytes), "proof-bytes")
+}
+
+func Benchmark_KaryTreeBuild(b *testing.B) {
+	leaves := benchmarkLeaves(4096)
+	for _, arity := range []int{2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("arity=%d", arity), func(b *testing.B) {
+			var tree *KaryTree
+			for run := 0; run < b.N; run++ {
+				tree, _ = NewKaryTree(arity)
+				for _, leaf := range leaves {
+					tree.Insert(leaf)
+				}
+			}
+			proof, _ := tree.GenerateProof(LogLeafHash(leaves[len(leaves)-1]))
+			proofBytes := len(proof.LeafHash)
+			for _, level := range proof.Levels {
+				for _, sibling := range level.Siblings {
+					proofBytes += len(sibling)
+				}
+			}
+			b.ReportMetric(float64(proofBytes), "proof-bytes")
+			b.ReportMetric(float64(len(proof.Levels)), "proof-levels")
+		})
+	}
+}
@@ -4,6 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+)
+
+var (
+	// ErrHashExists is returned when inserting data whose hash is already
+	// part of the tree.
+	ErrHashExists = errors.New("Hash already exists. Use Update() to update an existing hash")
+	// ErrHashNotFound is returned when a hash is neither a current nor a
+	// historical leaf hash.
+	ErrHashNotFound = errors.New("Hash not found")
 )
 
 // Mapping is used to help with managing and maintaining search
@@ -20,6 +30,12 @@ type Mapping struct {
 type MerkelTree struct {
 	root           *Node
 	lookupNodeList map[string]*Mapping
+	hooks          eventHooks
+	// sorted keeps leaves ordered by hash in a crit-bit shape, see
+	// InitSortedMerkelTree.
+	sorted bool
+	// aggregate annotates every node, see InitAnnotatedMerkelTree.
+	aggregate *Aggregate
 }
 
 type NodeDepth struct {
@@ -121,7 +137,7 @@ func (merkelTree *MerkelTree) Lookup(hash []byte) (*Node, error) {
 			}
 		}
 
-		return nil, errors.New(fmt.Sprintf("hash (%v) not found", hash))
+		return nil, fmt.Errorf("%w: %v", ErrHashNotFound, hash)
 	}
 
 	return block.node, nil
@@ -133,7 +149,7 @@ func (merkelTree *MerkelTree) Lookup(hash []byte) (*Node, error) {
 //	an existing node data object is being interacted with.
 func (merkelTree *MerkelTree) newHash(node *Node, hash []byte) error {
 	if merkelTree.findHash(hash) {
-		return errors.New("Hash already exists. Use Update() to update an existing hash")
+		return ErrHashExists
 	}
 
 	merkelTree.lookupNodeList[string(hash)] = &Mapping{
@@ -152,20 +168,41 @@ func (merkelTree *MerkelTree) newHash(node *Node, hash []byte) error {
 //     being a leaf alongside the new child node just created.
 //  3. Every new insert after the initial 2 unique cases.
 func (merkelTree *MerkelTree) Insert(data []byte) ([]byte, error) {
+	return merkelTree.insert(data, nil)
+}
+
+// insert adds a leaf carrying annotation, which only annotated trees use.
+func (merkelTree *MerkelTree) insert(data, annotation []byte) ([]byte, error) {
+	// A leaf without an annotation would make every later Combine fail.
+	if (merkelTree.aggregate != nil) != (annotation != nil) {
+		return nil, errNotAnnotated
+	}
 	hash := Hash128(data)
+	oldRoot := merkelTree.rootHash()
 
 	// First check if this hash exists
 	if merkelTree.findHash(hash) {
-		return nil, errors.New("Hash already exists. Use Update() to update an existing hash")
+		return nil, ErrHashExists
 	}
 
 	var newNode *Node
-	// If the root is nil, make a new node
-	if merkelTree.root == nil {
+	// In sorted mode the shape only depends on the set of hashes, so the
+	// leaf goes wherever its hash belongs.
+	if merkelTree.sorted {
 		newNode, err := CreateNode(data, hash)
 		if err != nil {
 			return nil, err
 		}
+		newNode.annotation = annotation
+		merkelTree.insertSorted(newNode)
+		merkelTree.newHash(newNode, hash)
+		// If the root is nil, make a new node
+	} else if merkelTree.root == nil {
+		newNode, err := CreateNode(data, hash)
+		if err != nil {
+			return nil, err
+		}
+		newNode.annotation = annotation
 		err = merkelTree.newHash(newNode, hash)
 		if err != nil {
 			return nil, err
@@ -178,10 +215,10 @@ func (merkelTree *MerkelTree) Insert(data []byte) ([]byte, error) {
 			return nil, err
 		}
 
-		traverse := newNode.prev
-		for traverse != nil {
-			traverse.hash = GenerateHash(traverse.left.hash, traverse.right.hash)
-			traverse = traverse.prev
+		newNode.annotation = annotation
+		if err := merkelTree.rehash(newNode.prev); err != nil {
+			merkelTree.detach(newNode)
+			return nil, err
 		}
 		merkelTree.newHash(newNode, hash)
 		// Scenario after first and second inserts. Find all leaf heights and
@@ -200,10 +237,10 @@ func (merkelTree *MerkelTree) Insert(data []byte) ([]byte, error) {
 
 		// Insert at this shallow node.
 		newNode = InsertNode(targetNode.node, &targetNode.node.prev, data, hash)
-		traverse := newNode.prev
-		for traverse != nil {
-			traverse.hash = GenerateHash(traverse.left.hash, traverse.right.hash)
-			traverse = traverse.prev
+		newNode.annotation = annotation
+		if err := merkelTree.rehash(newNode.prev); err != nil {
+			merkelTree.detach(newNode)
+			return nil, err
 		}
 		merkelTree.newHash(newNode, hash)
 	}
@@ -211,6 +248,13 @@ func (merkelTree *MerkelTree) Insert(data []byte) ([]byte, error) {
 	//	merkelTree.NewHash(newNode, hash)
 	// Update all the parent hashs all the way up the stack.
 
+	merkelTree.emit(TreeEvent{
+		Kind:    EventInsert,
+		NewHash: hash,
+		OldRoot: oldRoot,
+		NewRoot: merkelTree.rootHash(),
+	})
+
 	return hash, nil
 }
 
@@ -247,62 +291,151 @@ func (merkelTree *MerkelTree) updateHashVersionHistory(oldHash, newHash []byte)
 func (merkelTree *MerkelTree) Update(newData, hash []byte) ([]byte, error) {
 	node, err := merkelTree.Lookup(hash)
 	if err != nil {
-		return nil, errors.New("Hash not found")
+		return nil, ErrHashNotFound
 	}
 
+	oldRoot := merkelTree.rootHash()
+	oldHash := node.hash
 	newHash := Hash128(newData)
-	node.data = newData
-	node.hash = newHash
-	node = node.prev
-
-	for node != nil {
-		node.hash = GenerateHash(node.left.hash, node.right.hash)
-		node = node.prev
+	if merkelTree.sorted {
+		// The new hash sorts somewhere else; move the leaf there.
+		if err := merkelTree.updateSorted(node, newData, newHash); err != nil {
+			return nil, err
+		}
+	} else {
+		node.data = newData
+		node.hash = newHash
+		merkelTree.rehash(node.prev)
 	}
 
 	merkelTree.updateHashVersionHistory(hash, newHash)
 
+	merkelTree.emit(TreeEvent{
+		Kind:    EventUpdate,
+		OldHash: oldHash,
+		NewHash: newHash,
+		OldRoot: oldRoot,
+		NewRoot: merkelTree.rootHash(),
+	})
+
 	return newHash, nil
 }
 
-// Visualizer is the MerkelTree version of treeDebug. As an endpoint, this seems
-// useful to have implemented
-func (merkelTree *MerkelTree) Visualizer(node *Node, prefix string, isLeft bool) {
-	if node == nil {
-		return
+// Delete removes the leaf referenced by hash (current or historical) from the
+// tree. The leaf's sibling takes the place of their shared parent branch and
+// every hash above it is regenerated. All lookup entries for the leaf,
+// including its hash history, are dropped.
+func (merkelTree *MerkelTree) Delete(hash []byte) error {
+	node, err := merkelTree.Lookup(hash)
+	if err != nil {
+		return ErrHashNotFound
 	}
+	oldRoot := merkelTree.rootHash()
+	oldHash := node.hash
+
+	merkelTree.detach(node)
 
-	if node.right != nil {
-		newPrefix := prefix
-		if isLeft {
-			newPrefix += "│   "
-		} else {
-			newPrefix += "    "
+	for key, mapping := range merkelTree.lookupNodeList {
+		if mapping.node == node {
+			delete(merkelTree.lookupNodeList, key)
 		}
-		merkelTree.Visualizer(node.right, newPrefix, false)
 	}
 
-	fmt.Printf("%s", prefix)
-	if isLeft {
-		fmt.Printf("├── ")
+	merkelTree.emit(TreeEvent{
+		Kind:    EventDelete,
+		OldHash: oldHash,
+		OldRoot: oldRoot,
+		NewRoot: merkelTree.rootHash(),
+	})
+
+	return nil
+}
+
+// detach unhooks a leaf from the tree: its sibling takes the place of their
+// shared parent branch and every hash above it is regenerated.
+func (merkelTree *MerkelTree) detach(node *Node) {
+	parent := node.prev
+	node.prev = nil
+	if parent == nil {
+		merkelTree.root = nil
+		return
+	}
+
+	sibling := parent.left
+	if sibling == node {
+		sibling = parent.right
+	}
+
+	grandParent := parent.prev
+	sibling.prev = grandParent
+	if grandParent == nil {
+		merkelTree.root = sibling
+	} else if grandParent.left == parent {
+		grandParent.left = sibling
 	} else {
-		fmt.Printf("└── ")
+		grandParent.right = sibling
 	}
-	fmt.Printf("%s\n", node.data)
 
-	if node.left != nil {
-		newPrefix := prefix
-		if isLeft {
-			newPrefix += "│   "
-		} else {
-			newPrefix += "    "
+	merkelTree.rehash(grandParent)
+}
+
+// commitment is the hash a node contributes to its parent: its own hash,
+// except for the leaves of sorted and annotated trees, whose hash stays
+// Hash128(data) for lookups.
+func (merkelTree *MerkelTree) commitment(node *Node) []byte {
+	if node.left != nil || node.right != nil {
+		return node.hash
+	}
+	switch {
+	case merkelTree.sorted:
+		return sortedLeafHash(node.hash)
+	case merkelTree.aggregate != nil:
+		return annotatedLeafHash(node.hash, node.annotation)
+	}
+	return node.hash
+}
+
+// rehash regenerates the hashes from node up to the root, along with the
+// annotations of an annotated tree. It only fails when Combine does.
+func (merkelTree *MerkelTree) rehash(node *Node) error {
+	for ; node != nil; node = node.prev {
+		switch {
+		case merkelTree.sorted:
+			node.hash = sortedNodeHash(merkelTree.commitment(node.left), merkelTree.commitment(node.right))
+			continue
+		case merkelTree.aggregate == nil:
+			node.hash = GenerateHash(node.left.hash, node.right.hash)
+			continue
+		}
+
+		annotation, err := merkelTree.aggregate.Combine(node.left.annotation, node.right.annotation)
+		if err != nil {
+			return err
 		}
-		merkelTree.Visualizer(node.left, newPrefix, true)
+		node.annotation = annotation
+		node.hash = annotatedNodeHash(
+			merkelTree.commitment(node.left), node.left.annotation,
+			merkelTree.commitment(node.right), node.right.annotation,
+		)
 	}
+	return nil
+}
+
+// Visualizer is the MerkelTree version of treeDebug. As an endpoint, this seems
+// useful to have implemented. Use Render to write the tree somewhere other
+// than stdout or in another format.
+func (merkelTree *MerkelTree) Visualizer(node *Node, prefix string, isLeft bool) {
+	r := &renderer{w: os.Stdout}
+	r.text(node, prefix, isLeft, 0)
 }
 
 func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
+// runDemo walks through every tree operation on a small tree of letters.
+// It's what the binary runs when no command is given.
+func runDemo(printStats bool) {
 	tree := InitMerkelTree()
 	fmt.Println("First -- insert A")
 	hashA, err := tree.Insert([]byte("A"))
@@ -393,4 +526,8 @@ func main() {
 	tree.Update(nil, nil)
 	tree.Lookup(nil)
 	VerifyProof(nil, nil)
+
+	if printStats {
+		fmt.Print(tree.Stats())
+	}
 }
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// MerkelLog is the append-only mode of the merkel tree. Unlike MerkelTree,
+// whose shape depends on where Insert found the shallowest leaf, the log's
+// shape is a pure function of its size (RFC 6962/9162): leaves stay in
+// append order and the tree is split at the largest power of two. That's
+// what makes it possible to prove an older version of the log is a prefix
+// of a newer one (consistency proofs).
+//
+// Leaves and nodes are domain separated so a leaf can never pass as a node:
+//
+//	leaf hash = SHA-256(0x00 || data)
+//	node hash = SHA-256(0x01 || left || right)
+//
+// Unlike Hash128, log hashes keep all 32 bytes of SHA-256, so roots and
+// proofs are the ones CT, checkpoint and tile clients expect.
+type MerkelLog struct {
+	entries [][]byte
+	levels  hashLevels
+	// index maps a leaf hash to the first entry with that hash.
+	index map[string]uint64
+}
+
+// hashSource gives access to the hashes of complete subtrees. The proof
+// logic only relies on this, so it works the same for the in-memory log and
+// for logs read back from tiles.
+type hashSource interface {
+	// subtreeHash returns the hash of the complete subtree covering leaves
+	// [index << level, (index+1) << level).
+	subtreeHash(level int, index uint64) ([]byte, error)
+}
+
+// NewMerkelLog creates an empty log.
+func NewMerkelLog() *MerkelLog {
+	return &MerkelLog{
+		entries: [][]byte{},
+		levels:  hashLevels{},
+		index:   map[string]uint64{},
+	}
+}
+
+// logHash is the full SHA-256 digest of data.
+func logHash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// LogLeafHash is the hash of a log entry.
+func LogLeafHash(data []byte) []byte {
+	return logHash(append([]byte{0x00}, data...))
+}
+
+// logNodeHash is the hash of a log node from the hashes of its children.
+func logNodeHash(left, right []byte) []byte {
+	return logHash(append([]byte{0x01}, GenerateHash(left, right)...))
+}
+
+// Append adds an entry at the end of the log and returns its index and leaf
+// hash.
+func (merkelLog *MerkelLog) Append(data []byte) (uint64, []byte) {
+	index := uint64(len(merkelLog.entries))
+	hash := LogLeafHash(data)
+	merkelLog.entries = append(merkelLog.entries, append([]byte{}, data...))
+	if _, ok := merkelLog.index[string(hash)]; !ok {
+		merkelLog.index[string(hash)] = index
+	}
+
+	merkelLog.levels.push(hash)
+
+	return index, hash
+}
+
+// Size returns the number of entries in the log.
+func (merkelLog *MerkelLog) Size() uint64 {
+	return uint64(len(merkelLog.entries))
+}
+
+// Entry returns the data of entry index.
+func (merkelLog *MerkelLog) Entry(index uint64) ([]byte, error) {
+	if index >= merkelLog.Size() {
+		return nil, fmt.Errorf("entry %d out of range, log size is %d", index, merkelLog.Size())
+	}
+	return merkelLog.entries[index], nil
+}
+
+// IndexOf returns the index of the first entry with the given leaf hash.
+func (merkelLog *MerkelLog) IndexOf(leafHash []byte) (uint64, error) {
+	index, ok := merkelLog.index[string(leafHash)]
+	if !ok {
+		return 0, ErrHashNotFound
+	}
+	return index, nil
+}
+
+// RootHash returns the root hash of the whole log.
+func (merkelLog *MerkelLog) RootHash() []byte {
+	root, _ := merkelLog.RootAt(merkelLog.Size())
+	return root
+}
+
+// RootAt returns the root hash the log had when it held size entries.
+func (merkelLog *MerkelLog) RootAt(size uint64) ([]byte, error) {
+	if size > merkelLog.Size() {
+		return nil, fmt.Errorf("size %d is beyond the log size %d", size, merkelLog.Size())
+	}
+	return rootHash(merkelLog, size)
+}
+
+// InclusionProof returns the audit path proving entry index is part of the
+// log at the given size.
+func (merkelLog *MerkelLog) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > merkelLog.Size() {
+		return nil, fmt.Errorf("size %d is beyond the log size %d", size, merkelLog.Size())
+	}
+	return inclusionProof(merkelLog, index, size)
+}
+
+// ConsistencyProof returns the proof that the log at size first is a prefix
+// of the log at size second.
+func (merkelLog *MerkelLog) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	if second > merkelLog.Size() {
+		return nil, fmt.Errorf("size %d is beyond the log size %d", second, merkelLog.Size())
+	}
+	return consistencyProof(merkelLog, first, second)
+}
+
+func (merkelLog *MerkelLog) subtreeHash(level int, index uint64) ([]byte, error) {
+	return merkelLog.levels.subtreeHash(level, index)
+}
+
+// hashLevels holds the hashes of every complete subtree of an append-only
+// tree: levels[0] holds the leaf hashes and levels[k] the hashes of every
+// complete subtree of 2^k leaves, left to right. MerkelLog and MMR share it.
+type hashLevels [][][]byte
+
+// push appends a leaf hash along with the hash of every subtree it
+// completes. Only the hashes along the right edge are computed.
+func (levels *hashLevels) push(hash []byte) {
+	node := hash
+	for level := 0; ; level++ {
+		if level == len(*levels) {
+			*levels = append(*levels, [][]byte{})
+		}
+		(*levels)[level] = append((*levels)[level], node)
+		count := len((*levels)[level])
+		if count%2 == 1 {
+			return
+		}
+		node = logNodeHash((*levels)[level][count-2], (*levels)[level][count-1])
+	}
+}
+
+func (levels hashLevels) subtreeHash(level int, index uint64) ([]byte, error) {
+	if level >= len(levels) || index >= uint64(len(levels[level])) {
+		return nil, fmt.Errorf("no complete subtree at level %d index %d", level, index)
+	}
+	return levels[level][index], nil
+}
+
+// splitPoint returns the largest power of two smaller than n (n > 1).
+func splitPoint(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// rangeHash computes the hash of the subtree over leaves [lo, hi). Every
+// range reached from the root splits into complete, aligned subtrees.
+func rangeHash(source hashSource, lo, hi uint64) ([]byte, error) {
+	n := hi - lo
+	if n&(n-1) == 0 && lo%n == 0 {
+		return source.subtreeHash(bits.TrailingZeros64(n), lo/n)
+	}
+
+	k := splitPoint(n)
+	left, err := rangeHash(source, lo, lo+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := rangeHash(source, lo+k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return logNodeHash(left, right), nil
+}
+
+func rootHash(source hashSource, size uint64) ([]byte, error) {
+	if size == 0 {
+		return logHash(nil), nil
+	}
+	return rangeHash(source, 0, size)
+}
+
+func inclusionProof(source hashSource, index, size uint64) ([][]byte, error) {
+	if index >= size {
+		return nil, fmt.Errorf("index %d out of range, size is %d", index, size)
+	}
+
+	proof := [][]byte{}
+	lo, hi := uint64(0), size
+	// Walk down from the root, collecting siblings, then reverse so the
+	// proof runs from the leaf up like MerkelProof.
+	for hi-lo > 1 {
+		k := splitPoint(hi - lo)
+		var sibling []byte
+		var err error
+		if index < lo+k {
+			sibling, err = rangeHash(source, lo+k, hi)
+			hi = lo + k
+		} else {
+			sibling, err = rangeHash(source, lo, lo+k)
+			lo = lo + k
+		}
+		if err != nil {
+			return nil, err
+		}
+		proof = append(proof, sibling)
+	}
+	reverseHashes(proof)
+
+	return proof, nil
+}
+
+func consistencyProof(source hashSource, first, second uint64) ([][]byte, error) {
+	if first > second {
+		return nil, fmt.Errorf("first size %d is larger than second size %d", first, second)
+	}
+	if first == 0 || first == second {
+		return [][]byte{}, nil
+	}
+
+	proof := [][]byte{}
+	lo, hi := uint64(0), second
+	m := first
+	complete := true
+	for m != hi-lo {
+		k := splitPoint(hi - lo)
+		if m <= k {
+			sibling, err := rangeHash(source, lo+k, hi)
+			if err != nil {
+				return nil, err
+			}
+			proof = append(proof, sibling)
+			hi = lo + k
+		} else {
+			sibling, err := rangeHash(source, lo, lo+k)
+			if err != nil {
+				return nil, err
+			}
+			proof = append(proof, sibling)
+			m -= k
+			lo = lo + k
+			complete = false
+		}
+	}
+	// The old root itself is only needed when it isn't already known to the
+	// verifier, i.e. when we've stepped into a right subtree.
+	if !complete {
+		subtree, err := rangeHash(source, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+		proof = append(proof, subtree)
+	}
+	reverseHashes(proof)
+
+	return proof, nil
+}
+
+func reverseHashes(hashes [][]byte) {
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+}
+
+// VerifyInclusion checks an audit path from InclusionProof against the root
+// of a log of the given size (RFC 9162, 2.1.3.2).
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return fmt.Errorf("index %d out of range, size is %d", index, size)
+	}
+
+	fn, sn := index, size-1
+	hash := leafHash
+	for _, sibling := range proof {
+		if sn == 0 {
+			return errors.New("inclusion proof is too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			hash = logNodeHash(sibling, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = logNodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return errors.New("inclusion proof is too short")
+	}
+	if !bytes.Equal(hash, root) {
+		return errors.New("inclusion proof doesn't match the root")
+	}
+	return nil
+}
+
+// VerifyConsistency checks that the log with firstRoot at size first is a
+// prefix of the log with secondRoot at size second (RFC 9162, 2.1.4.2).
+func VerifyConsistency(first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) error {
+	switch {
+	case first > second:
+		return fmt.Errorf("first size %d is larger than second size %d", first, second)
+	case first == second:
+		if len(proof) != 0 {
+			return errors.New("consistency proof between equal sizes must be empty")
+		}
+		if !bytes.Equal(firstRoot, secondRoot) {
+			return errors.New("roots of the same size differ")
+		}
+		return nil
+	case first == 0:
+		if len(proof) != 0 {
+			return errors.New("consistency proof from an empty log must be empty")
+		}
+		return nil
+	case len(proof) == 0:
+		return errors.New("consistency proof is empty")
+	}
+
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	firstHash, secondHash := proof[0], proof[0]
+	for _, node := range proof[1:] {
+		if sn == 0 {
+			return errors.New("consistency proof is too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			firstHash = logNodeHash(node, firstHash)
+			secondHash = logNodeHash(node, secondHash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			secondHash = logNodeHash(secondHash, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return errors.New("consistency proof is too short")
+	}
+	if !bytes.Equal(firstHash, firstRoot) {
+		return errors.New("consistency proof doesn't match the first root")
+	}
+	if !bytes.Equal(secondHash, secondRoot) {
+		return errors.New("consistency proof doesn't match the second root")
+	}
+	return nil
+}
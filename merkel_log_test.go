@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// referenceRoot computes the RFC 6962 root hash recursively, straight from
+// the definition.
+func referenceRoot(entries [][]byte) []byte {
+	switch len(entries) {
+	case 0:
+		return logHash(nil)
+	case 1:
+		return LogLeafHash(entries[0])
+	}
+	k := splitPoint(uint64(len(entries)))
+	return logNodeHash(referenceRoot(entries[:k]), referenceRoot(entries[k:]))
+}
+
+func testLog(size int) (*MerkelLog, [][]byte) {
+	log := NewMerkelLog()
+	entries := [][]byte{}
+	for index := 0; index < size; index++ {
+		entry := []byte(fmt.Sprintf("entry %d", index))
+		log.Append(entry)
+		entries = append(entries, entry)
+	}
+	return log, entries
+}
+
+func Test_MerkelLog(t *testing.T) {
+	log, entries := testLog(40)
+
+	t.Run("Roots match the RFC 6962 definition", func(t *testing.T) {
+		for size := 0; size <= 40; size++ {
+			root, err := log.RootAt(uint64(size))
+			if err != nil {
+				t.Fatalf("Error: RootAt: %+v\n", err)
+			}
+			if !bytes.Equal(root, referenceRoot(entries[:size])) {
+				t.Errorf("Error: RootAt: root mismatch at size %d\n", size)
+			}
+		}
+		if _, err := log.RootAt(41); err == nil {
+			t.Errorf("Error: RootAt: size beyond the log accepted")
+		}
+	})
+
+	t.Run("RFC 6962 test vectors", func(t *testing.T) {
+		vectors := NewMerkelLog()
+		expected := map[uint64]string{
+			0: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			1: "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			2: "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+			3: "aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+			4: "d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+			8: "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+		}
+		for _, entry := range []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"} {
+			data, _ := hex.DecodeString(entry)
+			vectors.Append(data)
+		}
+		for size, root := range expected {
+			actual, _ := vectors.RootAt(size)
+			if hex.EncodeToString(actual) != root {
+				t.Errorf("Error: RootAt: size %d. Expected: %s, Actual: %x\n", size, root, actual)
+			}
+		}
+	})
+
+	t.Run("Inclusion proofs for every entry at every size", func(t *testing.T) {
+		for size := uint64(1); size <= 40; size++ {
+			root, _ := log.RootAt(size)
+			for index := uint64(0); index < size; index++ {
+				proof, err := log.InclusionProof(index, size)
+				if err != nil {
+					t.Fatalf("Error: InclusionProof: %+v\n", err)
+				}
+				if err := VerifyInclusion(LogLeafHash(entries[index]), index, size, proof, root); err != nil {
+					t.Errorf("Error: VerifyInclusion: index %d size %d: %+v\n", index, size, err)
+				}
+				if index+1 < size {
+					if VerifyInclusion(LogLeafHash(entries[index]), index+1, size, proof, root) == nil {
+						t.Errorf("Error: VerifyInclusion: index %d verified at the wrong position\n", index)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Consistency proofs between every pair of sizes", func(t *testing.T) {
+		for second := uint64(0); second <= 40; second++ {
+			secondRoot, _ := log.RootAt(second)
+			for first := uint64(0); first <= second; first++ {
+				firstRoot, _ := log.RootAt(first)
+				proof, err := log.ConsistencyProof(first, second)
+				if err != nil {
+					t.Fatalf("Error: ConsistencyProof: %+v\n", err)
+				}
+				if err := VerifyConsistency(first, second, firstRoot, secondRoot, proof); err != nil {
+					t.Errorf("Error: VerifyConsistency: %d -> %d: %+v\n", first, second, err)
+				}
+				if first > 0 && first < second {
+					if VerifyConsistency(first, second, secondRoot, secondRoot, proof) == nil {
+						t.Errorf("Error: VerifyConsistency: %d -> %d verified with the wrong first root\n", first, second)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Forked logs aren't consistent", func(t *testing.T) {
+		fork, _ := testLog(20)
+		fork.Append([]byte("forked entry"))
+		for fork.Size() < 30 {
+			fork.Append([]byte(fmt.Sprintf("fork %d", fork.Size())))
+		}
+		oldRoot, _ := log.RootAt(25)
+		proof, _ := fork.ConsistencyProof(25, 30)
+		if VerifyConsistency(25, 30, oldRoot, fork.RootHash(), proof) == nil {
+			t.Errorf("Error: VerifyConsistency: fork accepted")
+		}
+	})
+
+	t.Run("Entries and lookups", func(t *testing.T) {
+		entry, err := log.Entry(7)
+		if err != nil || string(entry) != "entry 7" {
+			t.Errorf("Error: Entry: %q %+v\n", entry, err)
+		}
+		index, err := log.IndexOf(LogLeafHash([]byte("entry 12")))
+		if err != nil || index != 12 {
+			t.Errorf("Error: IndexOf: %d %+v\n", index, err)
+		}
+		if _, err := log.IndexOf(LogLeafHash([]byte("missing"))); err == nil {
+			t.Errorf("Error: IndexOf: missing entry found")
+		}
+	})
+}
@@ -856,6 +856,31 @@ func Test_VerifyProof(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Malformed proofs are rejected without panicking", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		proof, _ := testMerkelTree.GenerateProof(Hash128([]byte("A")))
+
+		longer := &MerkelProof{
+			LeafHash:   proof.LeafHash,
+			ProofList:  append(proof.ProofList, Hash128([]byte("C"))),
+			Directions: append(proof.Directions, true),
+		}
+		if VerifyProof(longer, testMerkelTree.root.hash) {
+			t.Error("Error: VerifyProof: proof longer than root verified")
+		}
+
+		mismatched := &MerkelProof{
+			LeafHash:   proof.LeafHash,
+			ProofList:  proof.ProofList,
+			Directions: proof.Directions[:1],
+		}
+		if VerifyProof(mismatched, testMerkelTree.root.hash) {
+			t.Error("Error: VerifyProof: proof with missing directions verified")
+		}
+	})
 }
 
 func Test_MerkelTree(t *testing.T) {
@@ -1203,3 +1228,85 @@ func Test_MerkelTree(t *testing.T) {
 
 	})
 }
+
+func Test_Delete(t *testing.T) {
+	t.Run("Delete a leaf; its sibling replaces the parent", func(t *testing.T) {
+		//      changing this  ------------>  to this
+		//            O                          O
+		//          /   \                      /   \
+		//         O     O                    C     O
+		//       /  \   /  \                       /  \
+		//      C    A D    B                     D    B
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		testMerkelTree.Insert([]byte("C"))
+		testMerkelTree.Insert([]byte("D"))
+
+		if err := testMerkelTree.Delete(Hash128([]byte("A"))); err != nil {
+			t.Fatalf("Error: Delete: %+v\n", err)
+		}
+		expectedHash := GenerateHash(
+			Hash128([]byte("C")),
+			GenerateHash(Hash128([]byte("D")), Hash128([]byte("B"))),
+		)
+		if string(expectedHash) != string(testMerkelTree.root.hash) {
+			t.Errorf("Error: Delete: root hash mismatch: Expected %+v, Actual: %+v\n", expectedHash, testMerkelTree.root.hash)
+		}
+		if testMerkelTree.root.left.prev != testMerkelTree.root {
+			t.Errorf("Error: Delete: sibling not reattached to the grandparent")
+		}
+		if _, err := testMerkelTree.Lookup(Hash128([]byte("A"))); err == nil {
+			t.Errorf("Error: Delete: deleted hash can still be looked up")
+		}
+
+		proof, err := testMerkelTree.GenerateProof(Hash128([]byte("C")))
+		if err != nil {
+			t.Fatalf("Error: Delete: GenerateProof: %+v\n", err)
+		}
+		if !VerifyProof(proof, testMerkelTree.root.hash) {
+			t.Errorf("Error: Delete: proof for C no longer verifies")
+		}
+
+		// Deleted data can be inserted again.
+		if _, err := testMerkelTree.Insert([]byte("A")); err != nil {
+			t.Errorf("Error: Delete: reinsert failed: %+v\n", err)
+		}
+	})
+
+	t.Run("Delete using a historical hash", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		hashC, _ := testMerkelTree.Update([]byte("C"), Hash128([]byte("A")))
+
+		if err := testMerkelTree.Delete(Hash128([]byte("A"))); err != nil {
+			t.Fatalf("Error: Delete: %+v\n", err)
+		}
+		if _, err := testMerkelTree.Lookup(hashC); err == nil {
+			t.Errorf("Error: Delete: history of deleted node still resolves")
+		}
+		if testMerkelTree.root.prev != nil || string(testMerkelTree.root.data) != "B" {
+			t.Errorf("Error: Delete: B should be the new root")
+		}
+	})
+
+	t.Run("Delete every leaf", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		testMerkelTree.Insert([]byte("C"))
+
+		for _, data := range []string{"B", "A", "C"} {
+			if err := testMerkelTree.Delete(Hash128([]byte(data))); err != nil {
+				t.Fatalf("Error: Delete: %s: %+v\n", data, err)
+			}
+		}
+		if testMerkelTree.root != nil {
+			t.Errorf("Error: Delete: root should be nil")
+		}
+		if err := testMerkelTree.Delete(Hash128([]byte("A"))); err == nil {
+			t.Errorf("Error: Delete: nonexistent error not triggering")
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// MMR is a Merkle Mountain Range: an append-only accumulator kept as a list
+// of perfect binary trees (peaks), one per bit set in its size. Appending
+// only merges peaks of equal height, so it's O(log n), and every peak an
+// older size had is still stored, which is how proofs against older sizes
+// work without rebuilding anything.
+//
+// It stores and hashes its subtrees exactly like MerkelLog, through the
+// same hashLevels, and the root "bags" the peaks from right to left:
+//
+//	root = node(peak0, node(peak1, ... node(peakN-1, peakN)))
+type MMR struct {
+	// levels keeps every perfect tree, whether or not it's still a peak.
+	levels hashLevels
+	size   uint64
+}
+
+// MMRProof proves that a leaf is part of an MMR of a given size.
+type MMRProof struct {
+	LeafIndex uint64
+	Size      uint64
+	LeafHash  []byte
+	// Siblings run from the leaf up to the root of its peak.
+	Siblings [][]byte
+	// Peaks holds every peak of the MMR at Size, left to right.
+	Peaks [][]byte
+}
+
+// NewMMR creates an empty MMR.
+func NewMMR() *MMR {
+	return &MMR{levels: hashLevels{}}
+}
+
+// Size returns the number of leaves appended so far.
+func (mmr *MMR) Size() uint64 {
+	return mmr.size
+}
+
+// Append adds a leaf and returns its index and leaf hash.
+func (mmr *MMR) Append(data []byte) (uint64, []byte) {
+	index := mmr.size
+	hash := LogLeafHash(data)
+	mmr.levels.push(hash)
+	mmr.size++
+
+	return index, hash
+}
+
+// Peaks returns the peaks of the MMR at the given size, left to right.
+func (mmr *MMR) Peaks(size uint64) ([][]byte, error) {
+	if size > mmr.size {
+		return nil, fmt.Errorf("size %d is beyond the MMR size %d", size, mmr.size)
+	}
+
+	peaks := [][]byte{}
+	offset := uint64(0)
+	for level := bits.Len64(size) - 1; level >= 0; level-- {
+		if size&(1<<uint(level)) == 0 {
+			continue
+		}
+		peaks = append(peaks, mmr.levels[level][offset>>uint(level)])
+		offset += 1 << uint(level)
+	}
+	return peaks, nil
+}
+
+// bagPeaks folds the peaks into a single root, right to left.
+func bagPeaks(peaks [][]byte) []byte {
+	if len(peaks) == 0 {
+		return logHash(nil)
+	}
+	root := peaks[len(peaks)-1]
+	for index := len(peaks) - 2; index >= 0; index-- {
+		root = logNodeHash(peaks[index], root)
+	}
+	return root
+}
+
+// RootHash returns the bagged root of the MMR.
+func (mmr *MMR) RootHash() []byte {
+	root, _ := mmr.RootAt(mmr.size)
+	return root
+}
+
+// RootAt returns the bagged root the MMR had when it held size leaves.
+func (mmr *MMR) RootAt(size uint64) ([]byte, error) {
+	peaks, err := mmr.Peaks(size)
+	if err != nil {
+		return nil, err
+	}
+	return bagPeaks(peaks), nil
+}
+
+// mmrPeakOf finds the peak holding leaf index in an MMR of the given size
+// and returns its position in the peak list, its height and first leaf.
+func mmrPeakOf(index, size uint64) (position, height int, offset uint64) {
+	for level := bits.Len64(size) - 1; level >= 0; level-- {
+		if size&(1<<uint(level)) == 0 {
+			continue
+		}
+		if index < offset+1<<uint(level) {
+			return position, level, offset
+		}
+		offset += 1 << uint(level)
+		position++
+	}
+	return -1, 0, 0
+}
+
+// GenerateProof returns the inclusion proof of leaf index in the MMR at the
+// given size, which can be any size the MMR has had.
+func (mmr *MMR) GenerateProof(index, size uint64) (*MMRProof, error) {
+	if size > mmr.size {
+		return nil, fmt.Errorf("size %d is beyond the MMR size %d", size, mmr.size)
+	}
+	if index >= size {
+		return nil, fmt.Errorf("index %d out of range, size is %d", index, size)
+	}
+
+	_, height, _ := mmrPeakOf(index, size)
+	siblings := [][]byte{}
+	for level := 0; level < height; level++ {
+		siblings = append(siblings, mmr.levels[level][(index>>uint(level))^1])
+	}
+	peaks, _ := mmr.Peaks(size)
+
+	return &MMRProof{
+		LeafIndex: index,
+		Size:      size,
+		LeafHash:  mmr.levels[0][index],
+		Siblings:  siblings,
+		Peaks:     peaks,
+	}, nil
+}
+
+// VerifyMMRProof checks that the proof rebuilds its leaf's peak and that the
+// peaks bag up to rootHash. size must come from a trusted source, like
+// rootHash: it decides which peaks there are.
+func VerifyMMRProof(proof *MMRProof, size uint64, rootHash []byte) bool {
+	if proof == nil || proof.Size != size || proof.LeafIndex >= proof.Size {
+		return false
+	}
+	position, height, offset := mmrPeakOf(proof.LeafIndex, proof.Size)
+	if len(proof.Siblings) != height || len(proof.Peaks) != bits.OnesCount64(proof.Size) {
+		return false
+	}
+
+	hash := proof.LeafHash
+	local := proof.LeafIndex - offset
+	for level, sibling := range proof.Siblings {
+		if local>>uint(level)&1 == 1 {
+			hash = logNodeHash(sibling, hash)
+		} else {
+			hash = logNodeHash(hash, sibling)
+		}
+	}
+	if !compareHash(hash, proof.Peaks[position]) {
+		return false
+	}
+
+	return compareHash(bagPeaks(proof.Peaks), rootHash)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func Test_MMR(t *testing.T) {
+	mmr := NewMMR()
+	log := NewMerkelLog()
+	roots := [][]byte{mmr.RootHash()}
+	for index := 0; index < 21; index++ {
+		entry := []byte(fmt.Sprintf("entry %d", index))
+		mmr.Append(entry)
+		log.Append(entry)
+		roots = append(roots, mmr.RootHash())
+	}
+
+	t.Run("Peaks and roots", func(t *testing.T) {
+		for size := uint64(0); size <= mmr.Size(); size++ {
+			peaks, _ := mmr.Peaks(size)
+			expected := 0
+			for bits := size; bits > 0; bits >>= 1 {
+				expected += int(bits & 1)
+			}
+			if len(peaks) != expected {
+				t.Errorf("Error: Peaks: size %d Expected: %d, Actual: %d\n", size, expected, len(peaks))
+			}
+			// Bagging right to left gives the same root as the RFC 6962 log.
+			logRoot, _ := log.RootAt(size)
+			root, _ := mmr.RootAt(size)
+			if !bytes.Equal(root, logRoot) || !bytes.Equal(root, roots[size]) {
+				t.Errorf("Error: RootAt: size %d doesn't match the log root\n", size)
+			}
+		}
+		if _, err := mmr.RootAt(mmr.Size() + 1); err == nil {
+			t.Errorf("Error: RootAt: size beyond the MMR accepted")
+		}
+	})
+
+	t.Run("Proofs against every size", func(t *testing.T) {
+		for size := uint64(1); size <= mmr.Size(); size++ {
+			for index := uint64(0); index < size; index++ {
+				proof, err := mmr.GenerateProof(index, size)
+				if err != nil {
+					t.Fatalf("Error: GenerateProof: %+v\n", err)
+				}
+				if !VerifyMMRProof(proof, size, roots[size]) {
+					t.Errorf("Error: VerifyMMRProof: index %d size %d rejected\n", index, size)
+				}
+				if size < mmr.Size() && VerifyMMRProof(proof, mmr.Size(), mmr.RootHash()) {
+					t.Errorf("Error: VerifyMMRProof: index %d size %d accepted for the latest root\n", index, size)
+				}
+			}
+		}
+	})
+
+	t.Run("Tampered proofs", func(t *testing.T) {
+		proof, _ := mmr.GenerateProof(9, 19)
+		if !bytes.Equal(proof.LeafHash, LogLeafHash([]byte("entry 9"))) {
+			t.Errorf("Error: GenerateProof: wrong leaf hash")
+		}
+
+		forged := *proof
+		forged.LeafHash = LogLeafHash([]byte("entry 10"))
+		if VerifyMMRProof(&forged, 19, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: forged leaf accepted")
+		}
+		forged = *proof
+		forged.LeafIndex = 8
+		if VerifyMMRProof(&forged, 19, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: wrong index accepted")
+		}
+		forged = *proof
+		forged.Siblings = forged.Siblings[1:]
+		if VerifyMMRProof(&forged, 19, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: short proof accepted")
+		}
+		if VerifyMMRProof(proof, 18, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: proof accepted for another size")
+		}
+		forged = *proof
+		forged.Size = 18
+		if VerifyMMRProof(&forged, 19, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: prover chosen size accepted")
+		}
+		if VerifyMMRProof(nil, 19, roots[19]) {
+			t.Errorf("Error: VerifyMMRProof: nil proof accepted")
+		}
+		if _, err := mmr.GenerateProof(19, 19); err == nil {
+			t.Errorf("Error: GenerateProof: index out of range accepted")
+		}
+	})
+}
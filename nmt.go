@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// NamespacedTree is a namespaced Merkle tree in the style of Celestia's
+// NMT. Every leaf is tagged with a fixed size namespace ID, leaves are kept
+// sorted by namespace and every node commits to the smallest and largest
+// namespace below it. That lets a proof show it returned every leaf of a
+// namespace, or that a namespace has no leaves at all.
+//
+// The tree has the same shape as MerkelLog. Node values are the min and
+// max namespace followed by a digest:
+//
+//	leaf = ns || ns || Hash128(0x00 || ns || data)
+//	node = min(left) || max(right) || Hash128(0x01 || left || right)
+type NamespacedTree struct {
+	namespaceSize int
+	namespaces    [][]byte
+	leaves        [][]byte
+}
+
+// NamespaceProof proves which leaves of a tree belong to a namespace.
+// Leaves [Start, End) are the ones returned with the proof; an empty range
+// proves the namespace is absent. Nodes are the subtree values covering
+// every other leaf, left to right.
+type NamespaceProof struct {
+	Start int
+	End   int
+	Size  int
+	Nodes [][]byte
+}
+
+// NewNamespacedTree creates an empty tree for namespace IDs of the given
+// size in bytes.
+func NewNamespacedTree(namespaceSize int) (*NamespacedTree, error) {
+	if namespaceSize < 1 {
+		return nil, fmt.Errorf("invalid namespace size %d", namespaceSize)
+	}
+	return &NamespacedTree{
+		namespaceSize: namespaceSize,
+		namespaces:    [][]byte{},
+		leaves:        [][]byte{},
+	}, nil
+}
+
+// Len returns the number of leaves.
+func (tree *NamespacedTree) Len() int {
+	return len(tree.leaves)
+}
+
+// Push appends a leaf. Leaves must be pushed in namespace order; a
+// namespace smaller than the last one is rejected.
+func (tree *NamespacedTree) Push(namespace, data []byte) error {
+	if len(namespace) != tree.namespaceSize {
+		return fmt.Errorf("namespace must be %d bytes, got %d", tree.namespaceSize, len(namespace))
+	}
+	if count := len(tree.namespaces); count > 0 && bytes.Compare(namespace, tree.namespaces[count-1]) < 0 {
+		return errors.New("leaves must be pushed in namespace order")
+	}
+
+	tree.namespaces = append(tree.namespaces, append([]byte{}, namespace...))
+	tree.leaves = append(tree.leaves, append([]byte{}, data...))
+	return nil
+}
+
+// emptyNamespacedRoot is the root of a tree without leaves.
+func emptyNamespacedRoot(namespaceSize int) []byte {
+	return append(make([]byte, 2*namespaceSize), Hash128(nil)...)
+}
+
+func namespacedLeaf(namespace, data []byte) []byte {
+	digest := Hash128(append(append([]byte{0x00}, namespace...), data...))
+	return append(append(append([]byte{}, namespace...), namespace...), digest...)
+}
+
+// namespacedNode combines two node values. The left subtree's namespaces
+// must not be larger than the right one's.
+func namespacedNode(namespaceSize int, left, right []byte) ([]byte, error) {
+	if len(left) != 2*namespaceSize+16 || len(right) != 2*namespaceSize+16 {
+		return nil, errors.New("malformed namespaced node")
+	}
+	if bytes.Compare(left[namespaceSize:2*namespaceSize], right[:namespaceSize]) > 0 {
+		return nil, errors.New("namespaced nodes are out of order")
+	}
+
+	digest := Hash128(append(append([]byte{0x01}, left...), right...))
+	node := append([]byte{}, left[:namespaceSize]...)
+	node = append(node, right[namespaceSize:2*namespaceSize]...)
+	return append(node, digest...), nil
+}
+
+// subtree returns the value of the node over leaves [lo, hi).
+func (tree *NamespacedTree) subtree(lo, hi int) []byte {
+	if hi-lo == 1 {
+		return namespacedLeaf(tree.namespaces[lo], tree.leaves[lo])
+	}
+	k := lo + int(splitPoint(uint64(hi-lo)))
+	// Leaves are pushed in order, so combining can't fail.
+	node, _ := namespacedNode(tree.namespaceSize, tree.subtree(lo, k), tree.subtree(k, hi))
+	return node
+}
+
+// Root returns the root value: the min and max namespace of the tree
+// followed by its digest.
+func (tree *NamespacedTree) Root() []byte {
+	if len(tree.leaves) == 0 {
+		return emptyNamespacedRoot(tree.namespaceSize)
+	}
+	return tree.subtree(0, len(tree.leaves))
+}
+
+// ProveNamespace returns every leaf of namespace along with the proof that
+// no other leaf has it. A namespace without leaves gets an absence proof.
+func (tree *NamespacedTree) ProveNamespace(namespace []byte) ([][]byte, *NamespaceProof, error) {
+	if len(namespace) != tree.namespaceSize {
+		return nil, nil, fmt.Errorf("namespace must be %d bytes, got %d", tree.namespaceSize, len(namespace))
+	}
+
+	size := len(tree.leaves)
+	start := sort.Search(size, func(index int) bool {
+		return bytes.Compare(tree.namespaces[index], namespace) >= 0
+	})
+	end := sort.Search(size, func(index int) bool {
+		return bytes.Compare(tree.namespaces[index], namespace) > 0
+	})
+
+	proof := &NamespaceProof{Start: start, End: end, Size: size, Nodes: [][]byte{}}
+	if size > 0 {
+		tree.proofNodes(0, size, start, end, proof)
+	}
+	leaves := [][]byte{}
+	for _, leaf := range tree.leaves[start:end] {
+		leaves = append(leaves, append([]byte{}, leaf...))
+	}
+
+	return leaves, proof, nil
+}
+
+// proofNodes collects the values of the largest subtrees of [lo, hi) that
+// don't overlap [start, end).
+func (tree *NamespacedTree) proofNodes(lo, hi, start, end int, proof *NamespaceProof) {
+	switch {
+	case hi <= start || lo >= end:
+		proof.Nodes = append(proof.Nodes, tree.subtree(lo, hi))
+	case start <= lo && hi <= end:
+	default:
+		k := lo + int(splitPoint(uint64(hi-lo)))
+		tree.proofNodes(lo, k, start, end, proof)
+		tree.proofNodes(k, hi, start, end, proof)
+	}
+}
+
+// namespaceVerifier rebuilds a root from a NamespaceProof.
+type namespaceVerifier struct {
+	namespace []byte
+	leaves    [][]byte
+	proof     *NamespaceProof
+	next      int
+}
+
+func (verifier *namespaceVerifier) rebuild(lo, hi int) ([]byte, error) {
+	size := len(verifier.namespace)
+	start, end := verifier.proof.Start, verifier.proof.End
+
+	switch {
+	case hi <= start || lo >= end:
+		if verifier.next == len(verifier.proof.Nodes) {
+			return nil, errors.New("namespace proof is too short")
+		}
+		node := verifier.proof.Nodes[verifier.next]
+		verifier.next++
+		if len(node) != 2*size+16 {
+			return nil, errors.New("malformed namespaced node")
+		}
+		// Completeness: nothing left of the range may reach the namespace
+		// and nothing right of it may start at or below it.
+		if hi <= start && bytes.Compare(node[size:2*size], verifier.namespace) >= 0 {
+			return nil, errors.New("a node left of the range holds the namespace")
+		}
+		if lo >= end && bytes.Compare(node[:size], verifier.namespace) <= 0 {
+			return nil, errors.New("a node right of the range holds the namespace")
+		}
+		return node, nil
+	case hi-lo == 1:
+		return namespacedLeaf(verifier.namespace, verifier.leaves[lo-start]), nil
+	}
+
+	k := lo + int(splitPoint(uint64(hi-lo)))
+	left, err := verifier.rebuild(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := verifier.rebuild(k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return namespacedNode(size, left, right)
+}
+
+// VerifyNamespace checks that leaves are exactly the leaves of namespace
+// in the tree with the given root. An empty leaves list with a valid proof
+// means the namespace has no leaves.
+func VerifyNamespace(root, namespace []byte, leaves [][]byte, proof *NamespaceProof) error {
+	if proof == nil || len(namespace) == 0 {
+		return errors.New("missing proof or namespace")
+	}
+	if proof.Start < 0 || proof.Start > proof.End || proof.End > proof.Size || proof.End-proof.Start != len(leaves) {
+		return errors.New("namespace proof range doesn't match the leaves")
+	}
+
+	if proof.Size == 0 {
+		if len(proof.Nodes) != 0 || !bytes.Equal(root, emptyNamespacedRoot(len(namespace))) {
+			return errors.New("empty tree proof doesn't match the root")
+		}
+		return nil
+	}
+
+	verifier := &namespaceVerifier{namespace: namespace, leaves: leaves, proof: proof}
+	rebuilt, err := verifier.rebuild(0, proof.Size)
+	if err != nil {
+		return err
+	}
+	if verifier.next != len(proof.Nodes) {
+		return errors.New("namespace proof is too long")
+	}
+	if !bytes.Equal(rebuilt, root) {
+		return errors.New("namespace proof doesn't match the root")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func Test_NamespacedTree(t *testing.T) {
+	ns := func(id byte) []byte {
+		return []byte{0, id}
+	}
+	tree, _ := NewNamespacedTree(2)
+	// Namespaces 1, 3, 3, 3, 4, 7, 7, 9, 9, 9, 9.
+	layout := []byte{1, 3, 3, 3, 4, 7, 7, 9, 9, 9, 9}
+	for index, id := range layout {
+		if err := tree.Push(ns(id), []byte(fmt.Sprintf("blob %d", index))); err != nil {
+			t.Fatalf("Error: Push: %+v\n", err)
+		}
+	}
+	root := tree.Root()
+
+	t.Run("Root covers every namespace", func(t *testing.T) {
+		if !bytes.Equal(root[:2], ns(1)) || !bytes.Equal(root[2:4], ns(9)) {
+			t.Errorf("Error: Root: Expected: range 1-9, Actual: %x-%x\n", root[:2], root[2:4])
+		}
+		if err := tree.Push(ns(8), []byte("late")); err == nil {
+			t.Errorf("Error: Push: out of order namespace accepted")
+		}
+		if err := tree.Push([]byte{1}, []byte("short")); err == nil {
+			t.Errorf("Error: Push: short namespace accepted")
+		}
+	})
+
+	t.Run("Every namespace with its leaves", func(t *testing.T) {
+		for _, id := range []byte{1, 3, 4, 7, 9} {
+			leaves, proof, err := tree.ProveNamespace(ns(id))
+			if err != nil {
+				t.Fatalf("Error: ProveNamespace: %+v\n", err)
+			}
+			expected := bytes.Count(layout, []byte{id})
+			if len(leaves) != expected {
+				t.Errorf("Error: ProveNamespace: namespace %d Expected: %d leaves, Actual: %d\n", id, expected, len(leaves))
+			}
+			if err := VerifyNamespace(root, ns(id), leaves, proof); err != nil {
+				t.Errorf("Error: VerifyNamespace: namespace %d: %+v\n", id, err)
+			}
+		}
+	})
+
+	t.Run("Absent namespaces", func(t *testing.T) {
+		for _, id := range []byte{0, 2, 5, 8, 10} {
+			leaves, proof, _ := tree.ProveNamespace(ns(id))
+			if len(leaves) != 0 {
+				t.Errorf("Error: ProveNamespace: namespace %d has leaves\n", id)
+			}
+			if err := VerifyNamespace(root, ns(id), leaves, proof); err != nil {
+				t.Errorf("Error: VerifyNamespace: absence of %d: %+v\n", id, err)
+			}
+		}
+		empty, _ := NewNamespacedTree(2)
+		leaves, proof, _ := empty.ProveNamespace(ns(3))
+		if err := VerifyNamespace(empty.Root(), ns(3), leaves, proof); err != nil {
+			t.Errorf("Error: VerifyNamespace: empty tree: %+v\n", err)
+		}
+	})
+
+	t.Run("Omitted and forged leaves", func(t *testing.T) {
+		leaves, proof, _ := tree.ProveNamespace(ns(3))
+
+		// Dropping a leaf at the edge of the range and shrinking it.
+		short := *proof
+		short.End--
+		if err := VerifyNamespace(root, ns(3), leaves[:2], &short); err == nil {
+			t.Errorf("Error: VerifyNamespace: omitted leaf accepted")
+		}
+
+		forged := append([][]byte{}, leaves...)
+		forged[1] = []byte("forged")
+		if err := VerifyNamespace(root, ns(3), forged, proof); err == nil {
+			t.Errorf("Error: VerifyNamespace: forged leaf accepted")
+		}
+
+		// An honest proof for namespace 4 doesn't show 3 is absent.
+		_, other, _ := tree.ProveNamespace(ns(4))
+		hidden := *other
+		hidden.End = hidden.Start
+		if err := VerifyNamespace(root, ns(3), [][]byte{}, &hidden); err == nil {
+			t.Errorf("Error: VerifyNamespace: hidden namespace accepted as absent")
+		}
+
+		// Claiming namespace 3 is absent with an empty range in its middle.
+		absent := &NamespaceProof{Start: 2, End: 2, Size: tree.Len(), Nodes: [][]byte{}}
+		tree.proofNodes(0, tree.Len(), 2, 2, absent)
+		if err := VerifyNamespace(root, ns(3), [][]byte{}, absent); err == nil {
+			t.Errorf("Error: VerifyNamespace: false absence proof accepted")
+		}
+	})
+}
@@ -9,6 +9,8 @@ type Node struct {
 	prev  *Node
 	data  []byte
 	hash  []byte
+	// annotation is only set in annotated trees, see Aggregate.
+	annotation []byte
 }
 
 // CreateNode creates a new Merkel Leaf/branch node.
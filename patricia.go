@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotFound is returned when a key isn't part of a key-value tree.
+var ErrKeyNotFound = errors.New("key not found")
+
+// PatriciaTrie is an authenticated key-value map in the style of
+// Ethereum's Merkle Patricia trie. Keys are split into nibbles and a key's
+// position only depends on its bytes, so the root is a pure function of
+// the stored pairs no matter in which order they were written.
+//
+// There are three kinds of nodes:
+//
+//	leaf      the remaining nibbles of a key and its value
+//	extension a run of nibbles shared by every key below it
+//	branch    16 children, one per nibble, and the value of a key ending here
+//
+// Every node is hashed with Hash128 over its encoding, which starts with a
+// tag byte so node kinds can't be confused with each other.
+type PatriciaTrie struct {
+	root trieNode
+	size int
+}
+
+const (
+	trieLeafTag      = 0x00
+	trieExtensionTag = 0x01
+	trieBranchTag    = 0x02
+)
+
+type trieNode interface {
+	encode() []byte
+	nodeHash() []byte
+}
+
+type trieLeaf struct {
+	path  []byte
+	value []byte
+	hash  []byte
+}
+
+type trieExtension struct {
+	path  []byte
+	child trieNode
+	hash  []byte
+}
+
+type trieBranch struct {
+	children [16]trieNode
+	value    []byte
+	hasValue bool
+	hash     []byte
+}
+
+// NewPatriciaTrie creates an empty trie.
+func NewPatriciaTrie() *PatriciaTrie {
+	return &PatriciaTrie{}
+}
+
+// EmptyTrieRoot is the root hash of a trie without keys.
+func EmptyTrieRoot() []byte {
+	return Hash128(nil)
+}
+
+// RootHash returns the hash committing to every key and value in the trie.
+func (trie *PatriciaTrie) RootHash() []byte {
+	if trie.root == nil {
+		return EmptyTrieRoot()
+	}
+	return trie.root.nodeHash()
+}
+
+// Len returns the number of keys in the trie.
+func (trie *PatriciaTrie) Len() int {
+	return trie.size
+}
+
+// Get returns the value stored under key.
+func (trie *PatriciaTrie) Get(key []byte) ([]byte, error) {
+	path := keyNibbles(key)
+	node := trie.root
+	for node != nil {
+		switch current := node.(type) {
+		case *trieLeaf:
+			if bytes.Equal(current.path, path) {
+				return current.value, nil
+			}
+			node = nil
+		case *trieExtension:
+			if !bytes.HasPrefix(path, current.path) {
+				node = nil
+				break
+			}
+			path = path[len(current.path):]
+			node = current.child
+		case *trieBranch:
+			if len(path) == 0 {
+				if current.hasValue {
+					return current.value, nil
+				}
+				node = nil
+				break
+			}
+			node = current.children[path[0]]
+			path = path[1:]
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %x", ErrKeyNotFound, key)
+}
+
+// Put stores value under key, replacing any previous value.
+func (trie *PatriciaTrie) Put(key, value []byte) {
+	if _, err := trie.Get(key); err != nil {
+		trie.size++
+	}
+	trie.root = triePut(trie.root, keyNibbles(key), append([]byte{}, value...))
+}
+
+// Delete removes key from the trie.
+func (trie *PatriciaTrie) Delete(key []byte) error {
+	root, found := trieDelete(trie.root, keyNibbles(key))
+	if !found {
+		return fmt.Errorf("%w: %x", ErrKeyNotFound, key)
+	}
+	trie.root = root
+	trie.size--
+	return nil
+}
+
+// keyNibbles splits a key into its 4 bit nibbles, high nibble first.
+func keyNibbles(key []byte) []byte {
+	nibbles := make([]byte, 0, len(key)*2)
+	for _, b := range key {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	return nibbles
+}
+
+func commonPrefix(a, b []byte) int {
+	length := 0
+	for length < len(a) && length < len(b) && a[length] == b[length] {
+		length++
+	}
+	return length
+}
+
+func concatNibbles(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// trieAttach stores value below a branch: on the branch itself when path
+// is empty, otherwise in a leaf under the slot of path's first nibble.
+func trieAttach(branch *trieBranch, path, value []byte) {
+	if len(path) == 0 {
+		branch.value, branch.hasValue = value, true
+		return
+	}
+	branch.children[path[0]] = &trieLeaf{path: path[1:], value: value}
+}
+
+// triePut returns node with value stored at path. Nodes are never modified
+// in place, which keeps their cached hashes valid.
+func triePut(node trieNode, path, value []byte) trieNode {
+	switch current := node.(type) {
+	case nil:
+		return &trieLeaf{path: path, value: value}
+
+	case *trieLeaf:
+		shared := commonPrefix(current.path, path)
+		if shared == len(current.path) && shared == len(path) {
+			return &trieLeaf{path: path, value: value}
+		}
+		branch := &trieBranch{}
+		trieAttach(branch, current.path[shared:], current.value)
+		trieAttach(branch, path[shared:], value)
+		return trieExtend(path[:shared], branch)
+
+	case *trieExtension:
+		shared := commonPrefix(current.path, path)
+		if shared == len(current.path) {
+			return &trieExtension{path: current.path, child: triePut(current.child, path[shared:], value)}
+		}
+		branch := &trieBranch{}
+		rest := current.path[shared:]
+		branch.children[rest[0]] = trieExtend(rest[1:], current.child)
+		trieAttach(branch, path[shared:], value)
+		return trieExtend(path[:shared], branch)
+
+	case *trieBranch:
+		branch := &trieBranch{children: current.children, value: current.value, hasValue: current.hasValue}
+		if len(path) == 0 {
+			branch.value, branch.hasValue = value, true
+		} else {
+			branch.children[path[0]] = triePut(current.children[path[0]], path[1:], value)
+		}
+		return branch
+	}
+
+	panic("unknown trie node")
+}
+
+// trieExtend puts an extension with path above node, merging it into the
+// node when it's a leaf or an extension itself so the trie stays canonical.
+func trieExtend(path []byte, node trieNode) trieNode {
+	if len(path) == 0 {
+		return node
+	}
+	switch current := node.(type) {
+	case *trieLeaf:
+		return &trieLeaf{path: concatNibbles(path, current.path), value: current.value}
+	case *trieExtension:
+		return &trieExtension{path: concatNibbles(path, current.path), child: current.child}
+	}
+	return &trieExtension{path: append([]byte{}, path...), child: node}
+}
+
+// trieDelete returns node without path and whether path was found.
+func trieDelete(node trieNode, path []byte) (trieNode, bool) {
+	switch current := node.(type) {
+	case nil:
+		return nil, false
+
+	case *trieLeaf:
+		if !bytes.Equal(current.path, path) {
+			return node, false
+		}
+		return nil, true
+
+	case *trieExtension:
+		if !bytes.HasPrefix(path, current.path) {
+			return node, false
+		}
+		child, found := trieDelete(current.child, path[len(current.path):])
+		if !found {
+			return node, false
+		}
+		return trieExtend(current.path, child), true
+
+	case *trieBranch:
+		branch := &trieBranch{children: current.children, value: current.value, hasValue: current.hasValue}
+		if len(path) == 0 {
+			if !current.hasValue {
+				return node, false
+			}
+			branch.value, branch.hasValue = nil, false
+		} else {
+			child, found := trieDelete(current.children[path[0]], path[1:])
+			if !found {
+				return node, false
+			}
+			branch.children[path[0]] = child
+		}
+		return branch.collapse(), true
+	}
+
+	panic("unknown trie node")
+}
+
+// collapse replaces a branch left with a single entry by a leaf or an
+// extension, which is what the trie would look like had the removed key
+// never been there.
+func (branch *trieBranch) collapse() trieNode {
+	entries, last := 0, -1
+	for nibble, child := range branch.children {
+		if child != nil {
+			entries++
+			last = nibble
+		}
+	}
+	if branch.hasValue {
+		entries++
+	}
+
+	switch {
+	case entries > 1:
+		return branch
+	case branch.hasValue:
+		return &trieLeaf{path: []byte{}, value: branch.value}
+	case last >= 0:
+		return trieExtend([]byte{byte(last)}, branch.children[last])
+	}
+	return nil
+}
+
+func appendTrieBytes(encoded, data []byte) []byte {
+	encoded = binary.AppendUvarint(encoded, uint64(len(data)))
+	return append(encoded, data...)
+}
+
+func (leaf *trieLeaf) encode() []byte {
+	encoded := appendTrieBytes([]byte{trieLeafTag}, leaf.path)
+	return appendTrieBytes(encoded, leaf.value)
+}
+
+func (leaf *trieLeaf) nodeHash() []byte {
+	if leaf.hash == nil {
+		leaf.hash = Hash128(leaf.encode())
+	}
+	return leaf.hash
+}
+
+func (extension *trieExtension) encode() []byte {
+	encoded := appendTrieBytes([]byte{trieExtensionTag}, extension.path)
+	return append(encoded, extension.child.nodeHash()...)
+}
+
+func (extension *trieExtension) nodeHash() []byte {
+	if extension.hash == nil {
+		extension.hash = Hash128(extension.encode())
+	}
+	return extension.hash
+}
+
+// encode writes a bitmap of the occupied slots followed by their hashes,
+// then the branch value if there is one.
+func (branch *trieBranch) encode() []byte {
+	var bitmap uint16
+	hashes := []byte{}
+	for nibble, child := range branch.children {
+		if child != nil {
+			bitmap |= 1 << nibble
+			hashes = append(hashes, child.nodeHash()...)
+		}
+	}
+
+	encoded := binary.BigEndian.AppendUint16([]byte{trieBranchTag}, bitmap)
+	encoded = append(encoded, hashes...)
+	if !branch.hasValue {
+		return append(encoded, 0)
+	}
+	return appendTrieBytes(append(encoded, 1), branch.value)
+}
+
+func (branch *trieBranch) nodeHash() []byte {
+	if branch.hash == nil {
+		branch.hash = Hash128(branch.encode())
+	}
+	return branch.hash
+}
+
+// TrieProof holds the encoded nodes on the path from the root towards a
+// key. The same proof shows either the key's value or that it's absent.
+type TrieProof struct {
+	Nodes [][]byte
+}
+
+// Prove returns the proof for key, whether or not the key is present.
+func (trie *PatriciaTrie) Prove(key []byte) *TrieProof {
+	proof := &TrieProof{Nodes: [][]byte{}}
+	path := keyNibbles(key)
+	node := trie.root
+	for node != nil {
+		proof.Nodes = append(proof.Nodes, node.encode())
+		switch current := node.(type) {
+		case *trieLeaf:
+			node = nil
+		case *trieExtension:
+			if !bytes.HasPrefix(path, current.path) {
+				node = nil
+				break
+			}
+			path = path[len(current.path):]
+			node = current.child
+		case *trieBranch:
+			if len(path) == 0 {
+				node = nil
+				break
+			}
+			node = current.children[path[0]]
+			path = path[1:]
+		}
+	}
+	return proof
+}
+
+// decodedTrieNode is a node read back from a proof. Children are only
+// known by their hashes.
+type decodedTrieNode struct {
+	tag      byte
+	path     []byte
+	value    []byte
+	hasValue bool
+	child    []byte
+	children [16][]byte
+}
+
+// trieReadBytes reads a length prefixed field.
+func trieReadBytes(data []byte) ([]byte, []byte, error) {
+	length, read := binary.Uvarint(data)
+	if read <= 0 || length > uint64(len(data)-read) {
+		return nil, nil, errors.New("malformed trie node")
+	}
+	return data[read : read+int(length)], data[read+int(length):], nil
+}
+
+func trieReadPath(data []byte) ([]byte, []byte, error) {
+	path, rest, err := trieReadBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, nibble := range path {
+		if nibble > 0x0f {
+			return nil, nil, errors.New("malformed trie node: invalid nibble")
+		}
+	}
+	return path, rest, nil
+}
+
+func decodeTrieNode(data []byte) (*decodedTrieNode, error) {
+	if len(data) == 0 {
+		return nil, errors.New("malformed trie node")
+	}
+	node := &decodedTrieNode{tag: data[0]}
+	rest := data[1:]
+	var err error
+
+	switch node.tag {
+	case trieLeafTag:
+		if node.path, rest, err = trieReadPath(rest); err != nil {
+			return nil, err
+		}
+		if node.value, rest, err = trieReadBytes(rest); err != nil {
+			return nil, err
+		}
+	case trieExtensionTag:
+		if node.path, rest, err = trieReadPath(rest); err != nil {
+			return nil, err
+		}
+		if len(node.path) == 0 || len(rest) != 16 {
+			return nil, errors.New("malformed trie extension")
+		}
+		node.child, rest = rest, nil
+	case trieBranchTag:
+		if len(rest) < 2 {
+			return nil, errors.New("malformed trie branch")
+		}
+		bitmap := binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+		for nibble := range node.children {
+			if bitmap&(1<<nibble) == 0 {
+				continue
+			}
+			if len(rest) < 16 {
+				return nil, errors.New("malformed trie branch")
+			}
+			node.children[nibble], rest = rest[:16], rest[16:]
+		}
+		if len(rest) == 0 || rest[0] > 1 {
+			return nil, errors.New("malformed trie branch")
+		}
+		node.hasValue = rest[0] == 1
+		rest = rest[1:]
+		if node.hasValue {
+			if node.value, rest, err = trieReadBytes(rest); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown trie node tag %d", node.tag)
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("malformed trie node: trailing data")
+	}
+	return node, nil
+}
+
+// VerifyTrieProof checks a proof from Prove against a trusted root. It
+// returns the value stored under key, or found == false when the proof
+// shows the key is absent.
+func VerifyTrieProof(root, key []byte, proof *TrieProof) (value []byte, found bool, err error) {
+	if proof == nil {
+		return nil, false, errors.New("missing proof")
+	}
+	if len(proof.Nodes) == 0 {
+		if !bytes.Equal(root, EmptyTrieRoot()) {
+			return nil, false, errors.New("empty proof for a non-empty trie")
+		}
+		return nil, false, nil
+	}
+
+	path := keyNibbles(key)
+	expected := root
+	for index, encoded := range proof.Nodes {
+		if !bytes.Equal(Hash128(encoded), expected) {
+			return nil, false, fmt.Errorf("proof node %d doesn't match its parent", index)
+		}
+		node, err := decodeTrieNode(encoded)
+		if err != nil {
+			return nil, false, err
+		}
+		last := index == len(proof.Nodes)-1
+
+		switch node.tag {
+		case trieLeafTag:
+			if !last {
+				return nil, false, errors.New("proof continues past a leaf")
+			}
+			if bytes.Equal(node.path, path) {
+				return node.value, true, nil
+			}
+			return nil, false, nil
+		case trieExtensionTag:
+			if !bytes.HasPrefix(path, node.path) {
+				if !last {
+					return nil, false, errors.New("proof continues past a diverging extension")
+				}
+				return nil, false, nil
+			}
+			path = path[len(node.path):]
+			expected = node.child
+		case trieBranchTag:
+			if len(path) == 0 {
+				if !last {
+					return nil, false, errors.New("proof continues past the key")
+				}
+				if node.hasValue {
+					return node.value, true, nil
+				}
+				return nil, false, nil
+			}
+			expected = node.children[path[0]]
+			path = path[1:]
+			if expected == nil {
+				if !last {
+					return nil, false, errors.New("proof continues past an empty slot")
+				}
+				return nil, false, nil
+			}
+		}
+		if last {
+			return nil, false, errors.New("proof ends before reaching the key")
+		}
+	}
+
+	return nil, false, errors.New("proof ends before reaching the key")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func Test_PatriciaTrie(t *testing.T) {
+	keys := [][]byte{
+		[]byte("do"), []byte("dog"), []byte("doge"), []byte("horse"),
+		[]byte(""), []byte{0x00}, []byte{0x01, 0x23}, []byte{0x01, 0x24},
+	}
+	for index := 0; index < 40; index++ {
+		keys = append(keys, []byte(fmt.Sprintf("key %d", index)))
+	}
+	value := func(key []byte) []byte {
+		return append([]byte("value of "), key...)
+	}
+
+	t.Run("Put and Get", func(t *testing.T) {
+		trie := NewPatriciaTrie()
+		for _, key := range keys {
+			trie.Put(key, value(key))
+		}
+		trie.Put([]byte("dog"), []byte("puppy"))
+		for _, key := range keys {
+			expected := value(key)
+			if string(key) == "dog" {
+				expected = []byte("puppy")
+			}
+			actual, err := trie.Get(key)
+			if err != nil || !bytes.Equal(actual, expected) {
+				t.Errorf("Error: Get: %q Expected: %s, Actual: %s, %v\n", key, expected, actual, err)
+			}
+		}
+		if trie.Len() != len(keys) {
+			t.Errorf("Error: Len: Expected: %d, Actual: %d\n", len(keys), trie.Len())
+		}
+		if _, err := trie.Get([]byte("doggo")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: Get: Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+	})
+
+	t.Run("Root only depends on the content", func(t *testing.T) {
+		ordered := NewPatriciaTrie()
+		for _, key := range keys {
+			ordered.Put(key, value(key))
+		}
+		random := rand.New(rand.NewSource(1))
+		for round := 0; round < 5; round++ {
+			shuffled := NewPatriciaTrie()
+			for _, index := range random.Perm(len(keys)) {
+				shuffled.Put(keys[index], value(keys[index]))
+			}
+			if !bytes.Equal(ordered.RootHash(), shuffled.RootHash()) {
+				t.Errorf("Error: RootHash: insertion order changed the root")
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		trie := NewPatriciaTrie()
+		for _, key := range keys {
+			trie.Put(key, value(key))
+		}
+		for index, key := range keys {
+			if err := trie.Delete(key); err != nil {
+				t.Fatalf("Error: Delete: %q: %+v\n", key, err)
+			}
+			expected := NewPatriciaTrie()
+			for _, remaining := range keys[index+1:] {
+				expected.Put(remaining, value(remaining))
+			}
+			if !bytes.Equal(trie.RootHash(), expected.RootHash()) {
+				t.Fatalf("Error: Delete: root after deleting %q differs from a fresh trie\n", key)
+			}
+		}
+		if !bytes.Equal(trie.RootHash(), EmptyTrieRoot()) || trie.Len() != 0 {
+			t.Errorf("Error: Delete: trie isn't empty")
+		}
+		if err := trie.Delete([]byte("dog")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Error: Delete: Expected: %v, Actual: %v\n", ErrKeyNotFound, err)
+		}
+	})
+}
+
+func Test_VerifyTrieProof(t *testing.T) {
+	trie := NewPatriciaTrie()
+	for _, key := range []string{"do", "dog", "doge", "horse", "house"} {
+		trie.Put([]byte(key), []byte("value of "+key))
+	}
+	root := trie.RootHash()
+
+	t.Run("Inclusion", func(t *testing.T) {
+		for _, key := range []string{"do", "dog", "doge", "horse", "house"} {
+			value, found, err := VerifyTrieProof(root, []byte(key), trie.Prove([]byte(key)))
+			if err != nil || !found || string(value) != "value of "+key {
+				t.Errorf("Error: VerifyTrieProof: %s: %s, %v, %v\n", key, value, found, err)
+			}
+		}
+	})
+
+	t.Run("Exclusion", func(t *testing.T) {
+		for _, key := range []string{"d", "dogs", "cat", "hors", "", "zebra"} {
+			_, found, err := VerifyTrieProof(root, []byte(key), trie.Prove([]byte(key)))
+			if err != nil || found {
+				t.Errorf("Error: VerifyTrieProof: %q: Expected: absent, Actual: %v, %v\n", key, found, err)
+			}
+		}
+		_, found, err := VerifyTrieProof(EmptyTrieRoot(), []byte("dog"), NewPatriciaTrie().Prove([]byte("dog")))
+		if err != nil || found {
+			t.Errorf("Error: VerifyTrieProof: empty trie: %v, %v\n", found, err)
+		}
+	})
+
+	t.Run("Forged proofs", func(t *testing.T) {
+		proof := trie.Prove([]byte("dog"))
+		if _, _, err := VerifyTrieProof(root, []byte("doge"), proof); err == nil {
+			t.Errorf("Error: VerifyTrieProof: proof for dog accepted for doge")
+		}
+
+		tampered := &TrieProof{Nodes: append([][]byte{}, proof.Nodes...)}
+		last := append([]byte{}, tampered.Nodes[len(tampered.Nodes)-1]...)
+		last[len(last)-1] ^= 1
+		tampered.Nodes[len(tampered.Nodes)-1] = last
+		if _, _, err := VerifyTrieProof(root, []byte("dog"), tampered); err == nil {
+			t.Errorf("Error: VerifyTrieProof: tampered value accepted")
+		}
+
+		truncated := &TrieProof{Nodes: proof.Nodes[:1]}
+		if _, _, err := VerifyTrieProof(root, []byte("dog"), truncated); err == nil {
+			t.Errorf("Error: VerifyTrieProof: truncated proof accepted")
+		}
+		if _, _, err := VerifyTrieProof(root, []byte("dog"), &TrieProof{}); err == nil {
+			t.Errorf("Error: VerifyTrieProof: empty proof accepted for a non-empty trie")
+		}
+		if _, _, err := VerifyTrieProof(EmptyTrieRoot(), []byte("dog"), nil); err == nil {
+			t.Errorf("Error: VerifyTrieProof: nil proof accepted")
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// treeFileVersion is bumped whenever the saved layout changes.
+const treeFileVersion = 1
+
+// savedNode is the on-disk form of a Node. Parent pointers aren't stored,
+// they're rebuilt while loading.
+type savedNode struct {
+	Data  []byte     `json:"data"`
+	Hash  []byte     `json:"hash"`
+	Left  *savedNode `json:"left,omitempty"`
+	Right *savedNode `json:"right,omitempty"`
+}
+
+// savedMapping is the on-disk form of a lookupNodeList entry. Leaf is the
+// position of the node in Leaves().
+type savedMapping struct {
+	Key     []byte   `json:"key"`
+	Leaf    int      `json:"leaf"`
+	History [][]byte `json:"history"`
+}
+
+type savedTree struct {
+	Version int            `json:"version"`
+	Sorted  bool           `json:"sorted,omitempty"`
+	Root    *savedNode     `json:"root"`
+	Lookup  []savedMapping `json:"lookup"`
+}
+
+// Save writes the tree, including its exact shape and the hash history of
+// every leaf, as JSON so it can be restored with LoadMerkelTree.
+func (merkelTree *MerkelTree) Save(w io.Writer) error {
+	if merkelTree.aggregate != nil {
+		return errors.New("annotated trees can't be saved")
+	}
+
+	leafIndex := map[*Node]int{}
+	for index, leaf := range merkelTree.Leaves() {
+		leafIndex[leaf] = index
+	}
+
+	saved := savedTree{
+		Version: treeFileVersion,
+		Sorted:  merkelTree.sorted,
+		Root:    saveNode(merkelTree.root),
+		Lookup:  []savedMapping{},
+	}
+	for key, mapping := range merkelTree.lookupNodeList {
+		saved.Lookup = append(saved.Lookup, savedMapping{
+			Key:     []byte(key),
+			Leaf:    leafIndex[mapping.node],
+			History: mapping.hashUpdateHistroy,
+		})
+	}
+	// Map iteration is random; sort so saving the same tree twice gives the
+	// same file.
+	sort.Slice(saved.Lookup, func(i, j int) bool {
+		return string(saved.Lookup[i].Key) < string(saved.Lookup[j].Key)
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(saved)
+}
+
+func saveNode(node *Node) *savedNode {
+	if node == nil {
+		return nil
+	}
+
+	return &savedNode{
+		Data:  node.data,
+		Hash:  node.hash,
+		Left:  saveNode(node.left),
+		Right: saveNode(node.right),
+	}
+}
+
+// LoadMerkelTree restores a tree written by Save. Every hash, lookup entry
+// and, for sorted trees, the leaf order is checked before the tree is used.
+func LoadMerkelTree(r io.Reader) (*MerkelTree, error) {
+	saved := savedTree{}
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid tree file: %w", err)
+	}
+	if saved.Version != treeFileVersion {
+		return nil, fmt.Errorf("unsupported tree file version %d", saved.Version)
+	}
+
+	merkelTree := InitMerkelTree()
+	merkelTree.sorted = saved.Sorted
+	root, err := merkelTree.loadNode(saved.Root, nil)
+	if err != nil {
+		return nil, err
+	}
+	merkelTree.root = root
+
+	leaves := merkelTree.Leaves()
+	mapped := make([]bool, len(leaves))
+	for _, mapping := range saved.Lookup {
+		if mapping.Leaf < 0 || mapping.Leaf >= len(leaves) {
+			return nil, fmt.Errorf("invalid tree file: lookup entry points at leaf %d", mapping.Leaf)
+		}
+		history := mapping.History
+		if history == nil {
+			history = [][]byte{}
+		}
+		// The key is the hash the leaf was inserted with and the history
+		// every hash it was updated to since, so the leaf holds the last.
+		current := mapping.Key
+		if len(history) > 0 {
+			current = history[len(history)-1]
+		}
+		if !compareHash(current, leaves[mapping.Leaf].hash) {
+			return nil, fmt.Errorf("invalid tree file: lookup entry %x doesn't match leaf %d", mapping.Key, mapping.Leaf)
+		}
+		if mapped[mapping.Leaf] {
+			return nil, fmt.Errorf("invalid tree file: leaf %d has more than one lookup entry", mapping.Leaf)
+		}
+		mapped[mapping.Leaf] = true
+		merkelTree.lookupNodeList[string(mapping.Key)] = &Mapping{
+			node:              leaves[mapping.Leaf],
+			hashUpdateHistroy: history,
+		}
+	}
+	for index, ok := range mapped {
+		if !ok {
+			return nil, fmt.Errorf("invalid tree file: leaf %d has no lookup entry", index)
+		}
+	}
+
+	if merkelTree.sorted {
+		if err := checkSortedShape(leaves, merkelTree.rootHash()); err != nil {
+			return nil, err
+		}
+	}
+
+	return merkelTree, nil
+}
+
+// checkSortedShape makes sure a loaded sorted tree is the one its leaves
+// make, since absence proofs rely on the order and the crit-bit shape. The
+// leaves must be strictly increasing, and rebuilding the tree from them
+// must give the same root.
+func checkSortedShape(leaves []*Node, root []byte) error {
+	rebuilt := InitSortedMerkelTree()
+	for index, leaf := range leaves {
+		if index > 0 && bytes.Compare(leaves[index-1].hash, leaf.hash) >= 0 {
+			return errors.New("invalid tree file: sorted tree leaves out of order")
+		}
+		rebuilt.insertSorted(&Node{data: leaf.data, hash: leaf.hash})
+	}
+	if !compareHash(rebuilt.rootHash(), root) {
+		return errors.New("invalid tree file: sorted tree has the wrong shape")
+	}
+	return nil
+}
+
+func (merkelTree *MerkelTree) loadNode(saved *savedNode, prev *Node) (*Node, error) {
+	if saved == nil {
+		return nil, nil
+	}
+	if (saved.Left == nil) != (saved.Right == nil) {
+		return nil, errors.New("invalid tree file: branch with a single child")
+	}
+
+	node, err := CreateNode(saved.Data, saved.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree file: %w", err)
+	}
+	node.prev = prev
+	if node.left, err = merkelTree.loadNode(saved.Left, node); err != nil {
+		return nil, err
+	}
+	if node.right, err = merkelTree.loadNode(saved.Right, node); err != nil {
+		return nil, err
+	}
+
+	// Never trust the stored hashes; a tampered file must not load.
+	expected := Hash128(node.data)
+	if node.left != nil && merkelTree.sorted {
+		expected = sortedNodeHash(merkelTree.commitment(node.left), merkelTree.commitment(node.right))
+	} else if node.left != nil {
+		expected = GenerateHash(node.left.hash, node.right.hash)
+	}
+	if string(expected) != string(node.hash) {
+		return nil, errors.New("invalid tree file: hash mismatch")
+	}
+
+	return node, nil
+}
+
+// avlFileVersion is bumped whenever the saved AVLTree layout changes.
+const avlFileVersion = 1
+
+// savedAVLNode is the on-disk form of an avlNode. Inner nodes list the
+// positions of their children, which are always saved before them, so
+// nodes shared between versions are only written once.
+type savedAVLNode struct {
+	Key      []byte `json:"key,omitempty"`
+	Value    []byte `json:"value,omitempty"`
+	Children []int  `json:"children,omitempty"`
+}
+
+// savedAVLRoot points at the root of a version; Root is -1 for an empty
+// tree. Hash is checked against the rebuilt nodes when loading.
+type savedAVLRoot struct {
+	Version int64  `json:"version"`
+	Root    int    `json:"root"`
+	Hash    []byte `json:"hash"`
+}
+
+type savedAVLTree struct {
+	Version  int            `json:"version"`
+	Latest   int64          `json:"latest"`
+	Nodes    []savedAVLNode `json:"nodes"`
+	Working  savedAVLRoot   `json:"working"`
+	Versions []savedAVLRoot `json:"versions"`
+}
+
+// Save writes every saved version and the working tree as JSON so they can
+// be restored with LoadAVLTree.
+func (tree *AVLTree) Save(w io.Writer) error {
+	saved := savedAVLTree{
+		Version:  avlFileVersion,
+		Latest:   tree.latest,
+		Nodes:    []savedAVLNode{},
+		Versions: []savedAVLRoot{},
+	}
+	positions := map[*avlNode]int{}
+	var save func(node *avlNode) int
+	save = func(node *avlNode) int {
+		if node == nil {
+			return -1
+		}
+		if position, ok := positions[node]; ok {
+			return position
+		}
+		entry := savedAVLNode{Key: node.key, Value: node.value}
+		if !node.isLeaf() {
+			entry = savedAVLNode{Children: []int{save(node.left), save(node.right)}}
+		}
+		positions[node] = len(saved.Nodes)
+		saved.Nodes = append(saved.Nodes, entry)
+		return positions[node]
+	}
+
+	saved.Working = savedAVLRoot{Root: save(tree.working), Hash: avlRootHash(tree.working)}
+	for _, version := range tree.Versions() {
+		root := tree.versions[version]
+		saved.Versions = append(saved.Versions, savedAVLRoot{Version: version, Root: save(root), Hash: avlRootHash(root)})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(saved)
+}
+
+// LoadAVLTree restores a tree written by AVLTree.Save. Every node is
+// rehashed and every root checked, so a tampered file doesn't load, and
+// every inner node must keep its keys in order and be balanced, so the
+// loaded tree is one Set and Remove could have built.
+func LoadAVLTree(r io.Reader) (*AVLTree, error) {
+	saved := savedAVLTree{}
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid tree file: %w", err)
+	}
+	if saved.Version != avlFileVersion {
+		return nil, fmt.Errorf("unsupported tree file version %d", saved.Version)
+	}
+
+	nodes := []*avlNode{}
+	for position, entry := range saved.Nodes {
+		if entry.Children == nil {
+			nodes = append(nodes, newAVLLeaf(entry.Key, entry.Value))
+			continue
+		}
+		if len(entry.Children) != 2 {
+			return nil, errors.New("invalid tree file: inner node without two children")
+		}
+		left, right := entry.Children[0], entry.Children[1]
+		if left < 0 || left >= position || right < 0 || right >= position {
+			return nil, errors.New("invalid tree file: child saved after its parent")
+		}
+		if bytes.Compare(avlRightmost(nodes[left]).key, avlLeftmost(nodes[right]).key) >= 0 {
+			return nil, errors.New("invalid tree file: keys out of order")
+		}
+		if diff := nodes[left].height - nodes[right].height; diff < -1 || diff > 1 {
+			return nil, errors.New("invalid tree file: unbalanced node")
+		}
+		nodes = append(nodes, newAVLInner(nodes[left], nodes[right]))
+	}
+
+	root := func(saved savedAVLRoot) (*avlNode, error) {
+		if saved.Root < -1 || saved.Root >= len(nodes) {
+			return nil, fmt.Errorf("invalid tree file: root %d out of range", saved.Root)
+		}
+		var node *avlNode
+		if saved.Root >= 0 {
+			node = nodes[saved.Root]
+		}
+		if !compareHash(avlRootHash(node), saved.Hash) {
+			return nil, errors.New("invalid tree file: hash mismatch")
+		}
+		return node, nil
+	}
+
+	tree := NewAVLTree()
+	tree.latest = saved.Latest
+	var err error
+	if tree.working, err = root(saved.Working); err != nil {
+		return nil, err
+	}
+	for _, version := range saved.Versions {
+		if version.Version < 1 || version.Version > saved.Latest {
+			return nil, fmt.Errorf("invalid tree file: version %d out of range", version.Version)
+		}
+		if tree.versions[version.Version], err = root(version); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree, nil
+}
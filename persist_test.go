@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_SaveLoad(t *testing.T) {
+	t.Run("Round trip keeps shape, hashes and history", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		for _, data := range []string{"A", "B", "C", "D", "E"} {
+			testMerkelTree.Insert([]byte(data))
+		}
+		hashF, _ := testMerkelTree.Update([]byte("F"), Hash128([]byte("A")))
+
+		var saved bytes.Buffer
+		if err := testMerkelTree.Save(&saved); err != nil {
+			t.Fatalf("Error: Save: %+v\n", err)
+		}
+		loaded, err := LoadMerkelTree(bytes.NewReader(saved.Bytes()))
+		if err != nil {
+			t.Fatalf("Error: LoadMerkelTree: %+v\n", err)
+		}
+
+		if string(loaded.root.hash) != string(testMerkelTree.root.hash) {
+			t.Errorf("Error: LoadMerkelTree: root hash mismatch")
+		}
+		if walkData(loaded.Leaves()) != walkData(testMerkelTree.Leaves()) {
+			t.Errorf("Error: LoadMerkelTree: leaf order mismatch: %s\n", walkData(loaded.Leaves()))
+		}
+		// The historical hash of A still resolves to F.
+		node, err := loaded.Lookup(Hash128([]byte("A")))
+		if err != nil || string(node.data) != "F" {
+			t.Errorf("Error: LoadMerkelTree: hash history lost")
+		}
+		proof, err := loaded.GenerateProof(hashF)
+		if err != nil || !VerifyProof(proof, loaded.root.hash) {
+			t.Errorf("Error: LoadMerkelTree: parent pointers not restored")
+		}
+
+		// Saving again gives the exact same file.
+		var resaved bytes.Buffer
+		loaded.Save(&resaved)
+		if resaved.String() != saved.String() {
+			t.Errorf("Error: Save: output isn't deterministic")
+		}
+	})
+
+	t.Run("Empty tree", func(t *testing.T) {
+		var saved bytes.Buffer
+		InitMerkelTree().Save(&saved)
+		loaded, err := LoadMerkelTree(&saved)
+		if err != nil || loaded.root != nil {
+			t.Errorf("Error: LoadMerkelTree: empty tree round trip failed: %+v\n", err)
+		}
+	})
+
+	t.Run("Tampered files are rejected", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		var saved bytes.Buffer
+		testMerkelTree.Save(&saved)
+
+		// "QQ==" is base64 for "A".
+		tampered := strings.Replace(saved.String(), `"QQ=="`, `"Wg=="`, 1)
+		if _, err := LoadMerkelTree(strings.NewReader(tampered)); err == nil {
+			t.Errorf("Error: LoadMerkelTree: tampered data accepted")
+		}
+		if _, err := LoadMerkelTree(strings.NewReader("not json")); err == nil {
+			t.Errorf("Error: LoadMerkelTree: invalid JSON accepted")
+		}
+	})
+
+	// The files below have every hash recomputed, so only the checks on
+	// lookup entries and sorted order can catch them.
+	t.Run("Lookup entries must match their leaves", func(t *testing.T) {
+		testMerkelTree := InitMerkelTree()
+		testMerkelTree.Insert([]byte("A"))
+		testMerkelTree.Insert([]byte("B"))
+		a := testMerkelTree.lookupNodeList[string(Hash128([]byte("A")))]
+		b := testMerkelTree.lookupNodeList[string(Hash128([]byte("B")))]
+		a.node, b.node = b.node, a.node
+
+		var saved bytes.Buffer
+		testMerkelTree.Save(&saved)
+		if _, err := LoadMerkelTree(&saved); err == nil {
+			t.Errorf("Error: LoadMerkelTree: lookup entries pointing at the wrong leaves accepted")
+		}
+
+		a.node = b.node
+		saved.Reset()
+		testMerkelTree.Save(&saved)
+		if _, err := LoadMerkelTree(&saved); err == nil {
+			t.Errorf("Error: LoadMerkelTree: two lookup entries for one leaf accepted")
+		}
+	})
+
+	t.Run("Sorted trees must keep their order and shape", func(t *testing.T) {
+		branch := func(tree *MerkelTree, left *Node, right *Node) *Node {
+			node := &Node{left: left, right: right}
+			node.hash = sortedNodeHash(tree.commitment(left), tree.commitment(right))
+			left.prev, right.prev = node, node
+			return node
+		}
+
+		swapped := sortedTreeOf([]string{"A", "B", "C"})
+		swapped.root = branch(swapped, swapped.root.right, swapped.root.left)
+		var saved bytes.Buffer
+		swapped.Save(&saved)
+		if _, err := LoadMerkelTree(&saved); err == nil {
+			t.Errorf("Error: LoadMerkelTree: sorted leaves out of order accepted")
+		}
+
+		// Same leaves in the same order, regrouped the other way round.
+		regrouped := sortedTreeOf([]string{"A", "B", "C"})
+		leaves := regrouped.Leaves()
+		if regrouped.root.left.left == nil {
+			regrouped.root = branch(regrouped, branch(regrouped, leaves[0], leaves[1]), leaves[2])
+		} else {
+			regrouped.root = branch(regrouped, leaves[0], branch(regrouped, leaves[1], leaves[2]))
+		}
+		regrouped.root.prev = nil
+		saved.Reset()
+		regrouped.Save(&saved)
+		if _, err := LoadMerkelTree(&saved); err == nil {
+			t.Errorf("Error: LoadMerkelTree: sorted tree with the wrong shape accepted")
+		}
+	})
+}
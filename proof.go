@@ -8,6 +8,19 @@ type MerkelProof struct {
 	LeafHash   []byte
 	ProofList  [][]byte
 	Directions []bool
+	// Sorted is set on proofs from trees in sorted mode, which hash with
+	// sortedLeafHash and sortedNodeHash.
+	Sorted bool
+}
+
+// proofJSON is the text encoded form of a MerkelProof shared by the command
+// line tool and the HTTP server. Hashes are hex or base64 encoded.
+type proofJSON struct {
+	LeafHash   string   `json:"leafHash"`
+	ProofList  []string `json:"proofList"`
+	Directions []bool   `json:"directions"`
+	Sorted     bool     `json:"sorted,omitempty"`
+	Root       string   `json:"root,omitempty"`
 }
 
 // GenerateProof creates a new merkel proof. The logic takes the root node's hash
@@ -17,23 +30,34 @@ func (merkelTree *MerkelTree) GenerateProof(leafHash []byte) (*MerkelProof, erro
 	if merkelTree.root == nil {
 		return nil, errors.New("No root")
 	}
+	if merkelTree.aggregate != nil {
+		return nil, errors.New("annotated trees are proven with GenerateAnnotatedProof")
+	}
 
 	node, err := merkelTree.Lookup(leafHash)
 	if err != nil {
-		return nil, errors.New("Hash not found")
+		return nil, ErrHashNotFound
 	}
 
+	return merkelTree.proofPath(node), nil
+}
+
+// proofPath walks up from leaf and collects the sibling hashes and
+// directions on its way to root. It works on every kind of tree, so the
+// explorer and renderers use it for annotated trees too, whose proofs
+// VerifyProof can't check.
+func (merkelTree *MerkelTree) proofPath(node *Node) *MerkelProof {
 	proofChain := [][]byte{node.hash}
 	pathway := []bool{true}
 	current := node
 	previous := node.prev
 	for previous != nil {
 		if previous.left == current {
-			proofChain = append(proofChain, previous.right.hash)
+			proofChain = append(proofChain, merkelTree.commitment(previous.right))
 			pathway = append(pathway, true)
 		} else {
 
-			proofChain = append(proofChain, previous.left.hash)
+			proofChain = append(proofChain, merkelTree.commitment(previous.left))
 			pathway = append(pathway, false)
 		}
 		current = previous
@@ -41,10 +65,11 @@ func (merkelTree *MerkelTree) GenerateProof(leafHash []byte) (*MerkelProof, erro
 	}
 
 	return &MerkelProof{
-		LeafHash:   leafHash,
+		LeafHash:   node.hash,
 		ProofList:  proofChain,
 		Directions: pathway,
-	}, nil
+		Sorted:     merkelTree.sorted,
+	}
 }
 
 // VerifyProof verifies that a merkel proof is valid and can
@@ -52,11 +77,24 @@ func (merkelTree *MerkelTree) GenerateProof(leafHash []byte) (*MerkelProof, erro
 func VerifyProof(proof *MerkelProof, rootHash []byte) bool {
 	var value []byte
 
-	if proof == nil {
+	if proof == nil || len(proof.Directions) != len(proof.ProofList) {
+		return false
+	}
+	// The first entry is the leaf itself. Unless it's tied to LeafHash, a
+	// proof made of just the root verifies for any leaf.
+	if len(proof.ProofList) == 0 || len(proof.ProofList[0]) != hashSize || !compareHash(proof.ProofList[0], proof.LeafHash) {
 		return false
 	}
+	if proof.Sorted {
+		return verifySortedProof(proof, rootHash)
+	}
 
 	for index, hashPiece := range proof.ProofList {
+		// Every leaf hash is a Hash128, so every sibling is a whole number
+		// of them.
+		if len(hashPiece) == 0 || len(hashPiece)%hashSize != 0 {
+			return false
+		}
 		// Right join
 		if proof.Directions[index] {
 			value = GenerateHash(value, hashPiece)
@@ -68,3 +106,24 @@ func VerifyProof(proof *MerkelProof, rootHash []byte) bool {
 
 	return compareHash(value, rootHash)
 }
+
+// verifySortedProof verifies a proof from a tree in sorted mode, where the
+// leaf and every sibling are exactly one Hash128.
+func verifySortedProof(proof *MerkelProof, rootHash []byte) bool {
+	var value []byte
+	for index, hashPiece := range proof.ProofList {
+		if len(hashPiece) != hashSize {
+			return false
+		}
+		switch {
+		case index == 0:
+			value = sortedLeafHash(hashPiece)
+		case proof.Directions[index]:
+			value = sortedNodeHash(value, hashPiece)
+		default:
+			value = sortedNodeHash(hashPiece, value)
+		}
+	}
+
+	return len(proof.ProofList) > 0 && compareHash(value, rootHash)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_VerifyProofForgeries(t *testing.T) {
+	tree := InitMerkelTree()
+	for _, data := range []string{"A", "B", "C", "D"} {
+		tree.Insert([]byte(data))
+	}
+	root := tree.rootHash()
+	proof, _ := tree.GenerateProof(Hash128([]byte("A")))
+
+	t.Run("Genuine proof", func(t *testing.T) {
+		if !VerifyProof(proof, root) {
+			t.Error("Error: VerifyProof: genuine proof rejected")
+		}
+	})
+
+	t.Run("Root passed off as the leaf", func(t *testing.T) {
+		forged := &MerkelProof{
+			LeafHash:   Hash128([]byte("not in the tree")),
+			ProofList:  [][]byte{root},
+			Directions: []bool{true},
+		}
+		if VerifyProof(forged, root) {
+			t.Error("Error: VerifyProof: root accepted as the proof of another leaf")
+		}
+
+		sorted := sortedTreeOf([]string{"A", "B", "C"})
+		forged.ProofList = [][]byte{sorted.rootHash()}
+		if VerifyProof(forged, sorted.rootHash()) {
+			t.Error("Error: VerifyProof: sorted root accepted through the unsorted verifier")
+		}
+	})
+
+	t.Run("Leaf hash doesn't match the first entry", func(t *testing.T) {
+		forged := &MerkelProof{
+			LeafHash:   Hash128([]byte("B")),
+			ProofList:  proof.ProofList,
+			Directions: proof.Directions,
+		}
+		if VerifyProof(forged, root) {
+			t.Error("Error: VerifyProof: proof of A accepted for B")
+		}
+	})
+
+	t.Run("First entry isn't a single hash", func(t *testing.T) {
+		merged := append(append([]byte{}, proof.ProofList[0]...), proof.ProofList[1]...)
+		forged := &MerkelProof{
+			LeafHash:   merged,
+			ProofList:  append([][]byte{merged}, proof.ProofList[2:]...),
+			Directions: proof.Directions[1:],
+		}
+		if VerifyProof(forged, root) {
+			t.Error("Error: VerifyProof: two hashes accepted as one leaf")
+		}
+		if VerifyProof(&MerkelProof{ProofList: [][]byte{}, Directions: []bool{}}, root) {
+			t.Error("Error: VerifyProof: empty proof accepted")
+		}
+	})
+}
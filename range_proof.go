@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RangeProof proves that Entries are the log entries [Start, End) of a log
+// with Size entries. Nodes are the hashes of the largest subtrees outside
+// the range, left to right; there are at most about two per level, so
+// paging through a log costs far fewer hashes than one InclusionProof per
+// entry.
+type RangeProof struct {
+	Start   uint64
+	End     uint64
+	Size    uint64
+	Entries [][]byte
+	Nodes   [][]byte
+}
+
+// GenerateRangeProof returns entries [start, end) of the log at its
+// current size along with their range proof.
+func (merkelLog *MerkelLog) GenerateRangeProof(start, end uint64) (*RangeProof, error) {
+	size := merkelLog.Size()
+	if start >= end || end > size {
+		return nil, fmt.Errorf("invalid range [%d, %d) for a log of size %d", start, end, size)
+	}
+
+	proof := &RangeProof{Start: start, End: end, Size: size, Entries: [][]byte{}, Nodes: [][]byte{}}
+	for _, entry := range merkelLog.entries[start:end] {
+		proof.Entries = append(proof.Entries, append([]byte{}, entry...))
+	}
+	if err := rangeProofNodes(merkelLog, 0, size, start, end, proof); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// rangeProofNodes collects the hashes of the largest subtrees of [lo, hi)
+// that don't overlap [start, end).
+func rangeProofNodes(source hashSource, lo, hi, start, end uint64, proof *RangeProof) error {
+	switch {
+	case hi <= start || lo >= end:
+		hash, err := rangeHash(source, lo, hi)
+		if err != nil {
+			return err
+		}
+		proof.Nodes = append(proof.Nodes, hash)
+		return nil
+	case start <= lo && hi <= end:
+		return nil
+	}
+
+	k := lo + splitPoint(hi-lo)
+	if err := rangeProofNodes(source, lo, k, start, end, proof); err != nil {
+		return err
+	}
+	return rangeProofNodes(source, k, hi, start, end, proof)
+}
+
+// rangeVerifier rebuilds a root from a RangeProof.
+type rangeVerifier struct {
+	proof *RangeProof
+	next  int
+}
+
+func (verifier *rangeVerifier) rebuild(lo, hi uint64) ([]byte, error) {
+	proof := verifier.proof
+	switch {
+	case hi <= proof.Start || lo >= proof.End:
+		if verifier.next == len(proof.Nodes) {
+			return nil, errors.New("range proof is too short")
+		}
+		verifier.next++
+		return proof.Nodes[verifier.next-1], nil
+	case hi-lo == 1:
+		return LogLeafHash(proof.Entries[lo-proof.Start]), nil
+	}
+
+	k := lo + splitPoint(hi-lo)
+	left, err := verifier.rebuild(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := verifier.rebuild(k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return logNodeHash(left, right), nil
+}
+
+// VerifyRangeProof recomputes the root of a log of size entries from the
+// entries and boundary hashes in the proof and compares it with root. size
+// must come from a trusted source, like root: it fixes the tree's shape.
+func VerifyRangeProof(proof *RangeProof, size uint64, root []byte) error {
+	if proof == nil {
+		return errors.New("missing range proof")
+	}
+	if proof.Size != size {
+		return fmt.Errorf("range proof is for a log of size %d, not %d", proof.Size, size)
+	}
+	if proof.Start >= proof.End || proof.End > proof.Size || proof.End-proof.Start != uint64(len(proof.Entries)) {
+		return errors.New("range proof range doesn't match its entries")
+	}
+
+	verifier := &rangeVerifier{proof: proof}
+	rebuilt, err := verifier.rebuild(0, proof.Size)
+	if err != nil {
+		return err
+	}
+	if verifier.next != len(proof.Nodes) {
+		return errors.New("range proof is too long")
+	}
+	if !compareHash(rebuilt, root) {
+		return errors.New("range proof doesn't match the root")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/bits"
+	"testing"
+)
+
+func Test_RangeProof(t *testing.T) {
+	t.Run("Every range of every size", func(t *testing.T) {
+		for size := 1; size <= 20; size++ {
+			log, entries := testLog(size)
+			root := log.RootHash()
+			for start := uint64(0); start < uint64(size); start++ {
+				for end := start + 1; end <= uint64(size); end++ {
+					proof, err := log.GenerateRangeProof(start, end)
+					if err != nil {
+						t.Fatalf("Error: GenerateRangeProof: %+v\n", err)
+					}
+					if err := VerifyRangeProof(proof, uint64(size), root); err != nil {
+						t.Errorf("Error: VerifyRangeProof: [%d, %d) of %d: %+v\n", start, end, size, err)
+					}
+					if string(proof.Entries[0]) != string(entries[start]) {
+						t.Errorf("Error: GenerateRangeProof: wrong first entry %s\n", proof.Entries[0])
+					}
+					if len(proof.Nodes) > 2*bits.Len64(uint64(size)) {
+						t.Errorf("Error: GenerateRangeProof: %d nodes for [%d, %d) of %d\n", len(proof.Nodes), start, end, size)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Cheaper than individual proofs", func(t *testing.T) {
+		log, _ := testLog(1000)
+		proof, _ := log.GenerateRangeProof(300, 400)
+		individual := 0
+		for index := uint64(300); index < 400; index++ {
+			inclusion, _ := log.InclusionProof(index, log.Size())
+			individual += len(inclusion)
+		}
+		if len(proof.Nodes) >= individual/50 {
+			t.Errorf("Error: GenerateRangeProof: %d hashes, individual proofs need %d\n", len(proof.Nodes), individual)
+		}
+	})
+
+	t.Run("Tampered proofs", func(t *testing.T) {
+		log, _ := testLog(13)
+		root := log.RootHash()
+		proof, _ := log.GenerateRangeProof(4, 9)
+
+		tampered := *proof
+		tampered.Entries = append([][]byte{}, proof.Entries...)
+		tampered.Entries[2] = []byte("forged")
+		if VerifyRangeProof(&tampered, 13, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: forged entry accepted")
+		}
+
+		tampered = *proof
+		tampered.Entries = proof.Entries[1:]
+		tampered.Start++
+		if VerifyRangeProof(&tampered, 13, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: shifted range accepted")
+		}
+
+		tampered = *proof
+		tampered.Nodes = append(append([][]byte{}, proof.Nodes...), proof.Nodes[0])
+		if VerifyRangeProof(&tampered, 13, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: extra node accepted")
+		}
+
+		tampered = *proof
+		tampered.Size = 12
+		if VerifyRangeProof(&tampered, 13, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: wrong size accepted")
+		}
+		if VerifyRangeProof(&tampered, 12, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: prover chosen size accepted")
+		}
+		if VerifyRangeProof(proof, 12, root) == nil {
+			t.Errorf("Error: VerifyRangeProof: proof accepted for another size")
+		}
+
+		if _, err := log.GenerateRangeProof(5, 5); err == nil {
+			t.Errorf("Error: GenerateRangeProof: empty range accepted")
+		}
+		if _, err := log.GenerateRangeProof(10, 14); err == nil {
+			t.Errorf("Error: GenerateRangeProof: range beyond the log accepted")
+		}
+	})
+}
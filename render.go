@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RenderFormat selects the output format written by Render.
+type RenderFormat int
+
+const (
+	// RenderText draws the same sideways ASCII tree as Visualizer.
+	RenderText RenderFormat = iota
+	// RenderDOT writes a Graphviz digraph.
+	RenderDOT
+	// RenderMermaid writes a Mermaid flowchart.
+	RenderMermaid
+	// RenderJSON writes the tree as nested JSON objects.
+	RenderJSON
+)
+
+// RenderOptions controls what Render writes for every node. The zero value
+// renders node data only, which matches the output of Visualizer.
+type RenderOptions struct {
+	Format RenderFormat
+	// HideData drops the node data from every label.
+	HideData bool
+	// ShowHash adds the hex encoded node hash to every label.
+	ShowHash bool
+	// HashLength truncates hex hashes to the given number of characters.
+	// 0 prints the full hash.
+	HashLength int
+	// ShowDepth adds the depth of each node, root being 0.
+	ShowDepth bool
+	// Proof highlights every node on the proof's path from leaf to root.
+	// Only its LeafHash is read, so on annotated trees, which GenerateProof
+	// refuses, &MerkelProof{LeafHash: hash} does.
+	Proof *MerkelProof
+}
+
+// renderedNode is the JSON representation of a single node.
+type renderedNode struct {
+	Data        *string       `json:"data,omitempty"`
+	Hash        string        `json:"hash,omitempty"`
+	Depth       *int          `json:"depth,omitempty"`
+	OnProofPath bool          `json:"onProofPath,omitempty"`
+	Left        *renderedNode `json:"left,omitempty"`
+	Right       *renderedNode `json:"right,omitempty"`
+}
+
+// renderer carries the state shared by every format while a tree is written.
+type renderer struct {
+	w         io.Writer
+	opts      RenderOptions
+	proofPath map[*Node]bool
+	nextID    int
+	err       error
+}
+
+// Render writes the merkel tree to w in the format selected by opts.
+// Unlike Visualizer, the output can be sent anywhere; a log, a file or a
+// buffer that gets attached to a ticket.
+func (merkelTree *MerkelTree) Render(w io.Writer, opts RenderOptions) error {
+	proofPath, err := merkelTree.proofPathNodes(opts.Proof)
+	if err != nil {
+		return err
+	}
+
+	r := &renderer{
+		w:         w,
+		opts:      opts,
+		proofPath: proofPath,
+	}
+
+	switch opts.Format {
+	case RenderText:
+		r.text(merkelTree.root, "", false, 0)
+	case RenderDOT:
+		r.printf("digraph MerkelTree {\n")
+		r.printf("  node [shape=box, fontname=\"monospace\"];\n")
+		r.graph(merkelTree.root, 0)
+		r.printf("}\n")
+	case RenderMermaid:
+		r.printf("graph TD\n")
+		r.graph(merkelTree.root, 0)
+		r.printf("  classDef proof fill:#ffd27f,stroke:#d9480f,stroke-width:2px;\n")
+	case RenderJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r.json(merkelTree.root, 0))
+	default:
+		return fmt.Errorf("unknown render format %d", opts.Format)
+	}
+
+	return r.err
+}
+
+// proofPathNodes collects the nodes a proof walks through on its way from
+// the leaf up to root.
+func (merkelTree *MerkelTree) proofPathNodes(proof *MerkelProof) (map[*Node]bool, error) {
+	path := map[*Node]bool{}
+	if proof == nil {
+		return path, nil
+	}
+
+	node, err := merkelTree.Lookup(proof.LeafHash)
+	if err != nil {
+		return nil, errors.New("proof leaf is not part of this tree")
+	}
+	for node != nil {
+		path[node] = true
+		node = node.prev
+	}
+
+	return path, nil
+}
+
+func (r *renderer) printf(format string, args ...any) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = fmt.Fprintf(r.w, format, args...)
+}
+
+// hexHash encodes a hash, truncated to HashLength when requested.
+func (r *renderer) hexHash(hash []byte) string {
+	encoded := hex.EncodeToString(hash)
+	if r.opts.HashLength > 0 && len(encoded) > r.opts.HashLength {
+		return encoded[:r.opts.HashLength] + "…"
+	}
+	return encoded
+}
+
+// label builds the human readable description of a node.
+func (r *renderer) label(node *Node, depth int) string {
+	parts := []string{}
+	if !r.opts.HideData {
+		parts = append(parts, string(node.data))
+	}
+	if r.opts.ShowHash {
+		parts = append(parts, "["+r.hexHash(node.hash)+"]")
+	}
+	if r.opts.ShowDepth {
+		parts = append(parts, fmt.Sprintf("(depth %d)", depth))
+	}
+	return strings.Join(parts, " ")
+}
+
+// text mirrors Visualizer: right subtree on top, left subtree underneath.
+// Nodes on the highlighted proof path are marked with a trailing "*".
+func (r *renderer) text(node *Node, prefix string, isLeft bool, depth int) {
+	if node == nil {
+		return
+	}
+
+	if node.right != nil {
+		newPrefix := prefix
+		if isLeft {
+			newPrefix += "│   "
+		} else {
+			newPrefix += "    "
+		}
+		r.text(node.right, newPrefix, false, depth+1)
+	}
+
+	r.printf("%s", prefix)
+	if isLeft {
+		r.printf("├── ")
+	} else {
+		r.printf("└── ")
+	}
+	r.printf("%s", r.label(node, depth))
+	if r.proofPath[node] {
+		r.printf(" *")
+	}
+	r.printf("\n")
+
+	if node.left != nil {
+		newPrefix := prefix
+		if isLeft {
+			newPrefix += "│   "
+		} else {
+			newPrefix += "    "
+		}
+		r.text(node.left, newPrefix, true, depth+1)
+	}
+}
+
+// graph writes node declarations and edges for both DOT and Mermaid, which
+// share the same shape and only differ in syntax. It returns the node's id.
+func (r *renderer) graph(node *Node, depth int) string {
+	if node == nil {
+		return ""
+	}
+
+	id := fmt.Sprintf("n%d", r.nextID)
+	r.nextID++
+	onPath := r.proofPath[node]
+	label := r.label(node, depth)
+
+	if r.opts.Format == RenderDOT {
+		style := ""
+		if onPath {
+			style = ", color=\"#d9480f\", penwidth=2"
+		}
+		r.printf("  %s [label=%q%s];\n", id, label, style)
+	} else {
+		r.printf("  %s[\"%s\"]\n", id, mermaidEscape(label))
+		if onPath {
+			r.printf("  class %s proof\n", id)
+		}
+	}
+
+	for _, child := range []struct {
+		node *Node
+		side string
+	}{{node.left, "L"}, {node.right, "R"}} {
+		if child.node == nil {
+			continue
+		}
+		childID := r.graph(child.node, depth+1)
+		if r.opts.Format == RenderDOT {
+			style := ""
+			if onPath && r.proofPath[child.node] {
+				style = ", color=\"#d9480f\", penwidth=2"
+			}
+			r.printf("  %s -> %s [label=%q%s];\n", id, childID, child.side, style)
+		} else {
+			r.printf("  %s -->|%s| %s\n", id, child.side, childID)
+		}
+	}
+
+	return id
+}
+
+// json converts the tree into its JSON representation.
+func (r *renderer) json(node *Node, depth int) *renderedNode {
+	if node == nil {
+		return nil
+	}
+
+	rendered := &renderedNode{
+		OnProofPath: r.proofPath[node],
+		Left:        r.json(node.left, depth+1),
+		Right:       r.json(node.right, depth+1),
+	}
+	if !r.opts.HideData {
+		data := string(node.data)
+		rendered.Data = &data
+	}
+	if r.opts.ShowHash {
+		rendered.Hash = r.hexHash(node.hash)
+	}
+	if r.opts.ShowDepth {
+		nodeDepth := depth
+		rendered.Depth = &nodeDepth
+	}
+
+	return rendered
+}
+
+// mermaidEscape replaces the characters Mermaid can't take inside a quoted
+// label with their entity codes.
+func mermaidEscape(label string) string {
+	return strings.NewReplacer(
+		"\"", "#quot;",
+		"<", "#lt;",
+		">", "#gt;",
+	).Replace(label)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_Render(t *testing.T) {
+	//   The tree
+	//            O
+	//          /   \
+	//         O     O
+	//       /  \  /   \
+	//      C    A D    B
+	testMerkelTree := InitMerkelTree()
+	testMerkelTree.Insert([]byte("A"))
+	testMerkelTree.Insert([]byte("B"))
+	testMerkelTree.Insert([]byte("C"))
+	testMerkelTree.Insert([]byte("D"))
+
+	t.Run("Text output matches the Visualizer layout", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := testMerkelTree.Render(&out, RenderOptions{}); err != nil {
+			t.Fatalf("Error: Render: %+v\n", err)
+		}
+		expected := "" +
+			"        └── B\n" +
+			"    └── X\n" +
+			"        ├── D\n" +
+			"└── Y\n" +
+			"    │   └── A\n" +
+			"    ├── X\n" +
+			"    │   ├── C\n"
+		if out.String() != expected {
+			t.Errorf("Error: Render: text mismatch. Expected:\n%s\nActual:\n%s\n", expected, out.String())
+		}
+	})
+
+	t.Run("Text output with truncated hashes, depth and proof path", func(t *testing.T) {
+		proof, err := testMerkelTree.GenerateProof(Hash128([]byte("A")))
+		if err != nil {
+			t.Fatalf("Error: Render: GenerateProof: %+v\n", err)
+		}
+		var out bytes.Buffer
+		err = testMerkelTree.Render(&out, RenderOptions{
+			ShowHash:   true,
+			HashLength: 8,
+			ShowDepth:  true,
+			Proof:      proof,
+		})
+		if err != nil {
+			t.Fatalf("Error: Render: %+v\n", err)
+		}
+		hashA := hex.EncodeToString(Hash128([]byte("A")))[:8]
+		if !strings.Contains(out.String(), "A ["+hashA+"…] (depth 2) *") {
+			t.Errorf("Error: Render: leaf A is not highlighted:\n%s\n", out.String())
+		}
+		if strings.Count(out.String(), "*") != 3 {
+			t.Errorf("Error: Render: expected 3 highlighted nodes:\n%s\n", out.String())
+		}
+	})
+
+	t.Run("DOT and Mermaid output", func(t *testing.T) {
+		proof, _ := testMerkelTree.GenerateProof(Hash128([]byte("D")))
+
+		var dot bytes.Buffer
+		if err := testMerkelTree.Render(&dot, RenderOptions{Format: RenderDOT, Proof: proof}); err != nil {
+			t.Fatalf("Error: Render: %+v\n", err)
+		}
+		if !strings.HasPrefix(dot.String(), "digraph MerkelTree {") {
+			t.Errorf("Error: Render: DOT header missing:\n%s\n", dot.String())
+		}
+		if strings.Count(dot.String(), "->") != 6 {
+			t.Errorf("Error: Render: expected 6 DOT edges:\n%s\n", dot.String())
+		}
+		if !strings.Contains(dot.String(), "[label=\"D\", color=") {
+			t.Errorf("Error: Render: DOT proof path missing:\n%s\n", dot.String())
+		}
+
+		var mermaid bytes.Buffer
+		if err := testMerkelTree.Render(&mermaid, RenderOptions{Format: RenderMermaid, Proof: proof}); err != nil {
+			t.Fatalf("Error: Render: %+v\n", err)
+		}
+		if strings.Count(mermaid.String(), "-->") != 6 {
+			t.Errorf("Error: Render: expected 6 Mermaid edges:\n%s\n", mermaid.String())
+		}
+		if strings.Count(mermaid.String(), " proof\n") != 3 {
+			t.Errorf("Error: Render: expected 3 Mermaid proof nodes:\n%s\n", mermaid.String())
+		}
+	})
+
+	t.Run("JSON output", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := testMerkelTree.Render(&out, RenderOptions{Format: RenderJSON, ShowHash: true}); err != nil {
+			t.Fatalf("Error: Render: %+v\n", err)
+		}
+		var root renderedNode
+		if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+			t.Fatalf("Error: Render: invalid JSON: %+v\n", err)
+		}
+		if *root.Left.Right.Data != "A" {
+			t.Errorf("Error: Render: expected A at left.right, Actual: %s\n", *root.Left.Right.Data)
+		}
+		if root.Hash != hex.EncodeToString(testMerkelTree.root.hash) {
+			t.Errorf("Error: Render: root hash mismatch: %s\n", root.Hash)
+		}
+	})
+
+	t.Run("Proof from another tree", func(t *testing.T) {
+		otherTree := InitMerkelTree()
+		otherTree.Insert([]byte("Z"))
+		proof, _ := otherTree.GenerateProof(Hash128([]byte("Z")))
+
+		var out bytes.Buffer
+		if err := testMerkelTree.Render(&out, RenderOptions{Proof: proof}); err == nil {
+			t.Errorf("Error: Render: foreign proof not rejected")
+		}
+	})
+
+	t.Run("Empty tree", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := InitMerkelTree().Render(&out, RenderOptions{}); err != nil {
+			t.Errorf("Error: Render: %+v\n", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("Error: Render: expected no output, Actual: %q\n", out.String())
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultMaxRequestBytes caps the size of a request body unless the server
+// is configured otherwise.
+const DefaultMaxRequestBytes = 1 << 20
+
+// Server exposes a MerkelTree over HTTP/JSON. Binary values (data, hashes
+// and roots) are hex encoded unless the request asks for
+// "?encoding=base64", which then applies to both the request and the
+// response. base64 is the unpadded URL-safe alphabet, so a hash can be
+// used as a path segment as is.
+//
+//	POST /insert       {"data"}                  -> 201 {"hash", "root"}
+//	POST /update       {"hash", "data"}          -> 200 {"hash", "root"}
+//	GET  /lookup/{hash}                          -> 200 {"hash", "data"}
+//	GET  /proof/{hash}                           -> 200 {"leafHash", "proofList", "directions", "sorted", "root"}
+//	POST /verify       {"proof", "root"}         -> 200 {"valid"}
+//	GET  /root                                   -> 200 {"root", "leaves"}
+type Server struct {
+	// MaxRequestBytes caps request bodies; larger requests get a 413.
+	MaxRequestBytes int64
+
+	mu   sync.RWMutex
+	tree *MerkelTree
+	mux  *http.ServeMux
+}
+
+// NewServer creates a server for tree. The tree must not be modified other
+// than through the server afterwards; the server serialises access to it.
+func NewServer(tree *MerkelTree) *Server {
+	server := &Server{
+		MaxRequestBytes: DefaultMaxRequestBytes,
+		tree:            tree,
+		mux:             http.NewServeMux(),
+	}
+	server.mux.HandleFunc("POST /insert", server.handleInsert)
+	server.mux.HandleFunc("POST /update", server.handleUpdate)
+	server.mux.HandleFunc("GET /lookup/{hash}", server.handleLookup)
+	server.mux.HandleFunc("GET /proof/{hash}", server.handleProof)
+	server.mux.HandleFunc("POST /verify", server.handleVerify)
+	server.mux.HandleFunc("GET /root", server.handleRoot)
+
+	return server
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+var errBadEncoding = errors.New("unknown encoding, use hex or base64")
+
+// codec encodes and decodes binary values for a single request.
+type codec struct {
+	encode func([]byte) string
+	decode func(string) ([]byte, error)
+}
+
+func requestCodec(r *http.Request) (codec, error) {
+	switch r.URL.Query().Get("encoding") {
+	case "", "hex":
+		return codec{hex.EncodeToString, hex.DecodeString}, nil
+	case "base64":
+		return codec{base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString}, nil
+	default:
+		return codec{}, errBadEncoding
+	}
+}
+
+// decodeHash decodes a non-empty hash.
+func (c codec) decodeHash(value string) ([]byte, error) {
+	hash, err := c.decode(value)
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("invalid hash %q", value)
+	}
+	return hash, nil
+}
+
+// writeJSON writes body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError maps err onto an HTTP status code.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrHashNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrHashExists):
+		status = http.StatusConflict
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// readBody decodes a JSON request body of at most maxBytes into body.
+func readBody(w http.ResponseWriter, r *http.Request, maxBytes int64, body any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func (server *Server) handleInsert(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := struct {
+		Data *string `json:"data"`
+	}{}
+	if err := readBody(w, r, server.MaxRequestBytes, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Data == nil {
+		writeError(w, errors.New("data is required"))
+		return
+	}
+	data, err := c.decode(*body.Data)
+	if err != nil {
+		writeError(w, fmt.Errorf("invalid data: %w", err))
+		return
+	}
+
+	server.mu.Lock()
+	hash, err := server.tree.Insert(data)
+	root := server.tree.rootHash()
+	server.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"hash": c.encode(hash),
+		"root": c.encode(root),
+	})
+}
+
+func (server *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := struct {
+		Hash string  `json:"hash"`
+		Data *string `json:"data"`
+	}{}
+	if err := readBody(w, r, server.MaxRequestBytes, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	hash, err := c.decodeHash(body.Hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Data == nil {
+		writeError(w, errors.New("data is required"))
+		return
+	}
+	data, err := c.decode(*body.Data)
+	if err != nil {
+		writeError(w, fmt.Errorf("invalid data: %w", err))
+		return
+	}
+
+	server.mu.Lock()
+	newHash, err := server.tree.Update(data, hash)
+	root := server.tree.rootHash()
+	server.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"hash": c.encode(newHash),
+		"root": c.encode(root),
+	})
+}
+
+func (server *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	hash, err := c.decodeHash(r.PathValue("hash"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	node, err := server.tree.Lookup(hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"hash": c.encode(node.hash),
+		"data": c.encode(node.data),
+	})
+}
+
+func (server *Server) handleProof(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	hash, err := c.decodeHash(r.PathValue("hash"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	if server.tree.root == nil {
+		writeError(w, ErrHashNotFound)
+		return
+	}
+	proof, err := server.tree.GenerateProof(hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	body := proofJSON{
+		LeafHash:   c.encode(proof.LeafHash),
+		ProofList:  []string{},
+		Directions: proof.Directions,
+		Sorted:     proof.Sorted,
+		Root:       c.encode(server.tree.rootHash()),
+	}
+	for _, piece := range proof.ProofList {
+		body.ProofList = append(body.ProofList, c.encode(piece))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (server *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := struct {
+		Proof *proofJSON `json:"proof"`
+		Root  string     `json:"root"`
+	}{}
+	if err := readBody(w, r, server.MaxRequestBytes, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Proof == nil {
+		writeError(w, errors.New("proof is required"))
+		return
+	}
+	root, err := c.decodeHash(body.Root)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	proof := &MerkelProof{Directions: body.Proof.Directions, Sorted: body.Proof.Sorted}
+	if proof.LeafHash, err = c.decode(body.Proof.LeafHash); err != nil {
+		writeError(w, fmt.Errorf("invalid leaf hash: %w", err))
+		return
+	}
+	for _, piece := range body.Proof.ProofList {
+		hash, err := c.decode(piece)
+		if err != nil {
+			writeError(w, fmt.Errorf("invalid proof hash: %w", err))
+			return
+		}
+		proof.ProofList = append(proof.ProofList, hash)
+	}
+
+	// Verification is stateless, the tree isn't touched.
+	writeJSON(w, http.StatusOK, map[string]bool{"valid": VerifyProof(proof, root)})
+}
+
+func (server *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	server.mu.RLock()
+	root := server.tree.rootHash()
+	leaves := len(server.tree.Leaves())
+	server.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"root":   c.encode(root),
+		"leaves": leaves,
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// doJSON sends a request to the test server and decodes the JSON response.
+func doJSON(t *testing.T, method, url, body string, response any) int {
+	t.Helper()
+	request, _ := http.NewRequest(method, url, strings.NewReader(body))
+	result, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Error: Server: %s %s: %+v\n", method, url, err)
+	}
+	defer result.Body.Close()
+	if response != nil {
+		json.NewDecoder(result.Body).Decode(response)
+	}
+	return result.StatusCode
+}
+
+func Test_Server(t *testing.T) {
+	testServer := httptest.NewServer(NewServer(InitMerkelTree()))
+	defer testServer.Close()
+	hexA := hex.EncodeToString([]byte("A"))
+	hashA := hex.EncodeToString(Hash128([]byte("A")))
+
+	t.Run("Insert", func(t *testing.T) {
+		response := map[string]string{}
+		status := doJSON(t, "POST", testServer.URL+"/insert", `{"data":"`+hexA+`"}`, &response)
+		if status != http.StatusCreated || response["hash"] != hashA || response["root"] != hashA {
+			t.Errorf("Error: Server: insert: %d %+v\n", status, response)
+		}
+
+		base64B := base64.RawURLEncoding.EncodeToString([]byte("B"))
+		status = doJSON(t, "POST", testServer.URL+"/insert?encoding=base64", `{"data":"`+base64B+`"}`, &response)
+		if status != http.StatusCreated || response["hash"] != base64.RawURLEncoding.EncodeToString(Hash128([]byte("B"))) {
+			t.Errorf("Error: Server: base64 insert: %d %+v\n", status, response)
+		}
+		doJSON(t, "POST", testServer.URL+"/insert", `{"data":"`+hex.EncodeToString([]byte("C"))+`"}`, nil)
+
+		if status := doJSON(t, "POST", testServer.URL+"/insert", `{"data":"`+hexA+`"}`, nil); status != http.StatusConflict {
+			t.Errorf("Error: Server: duplicate insert, Expected: 409, Actual: %d\n", status)
+		}
+	})
+
+	t.Run("Bad requests", func(t *testing.T) {
+		for _, request := range []struct {
+			method, path, body string
+			status             int
+		}{
+			{"POST", "/insert", `{"data":"zz"}`, http.StatusBadRequest},
+			{"POST", "/insert", `{}`, http.StatusBadRequest},
+			{"POST", "/insert", `{"data":"41","extra":1}`, http.StatusBadRequest},
+			{"POST", "/insert?encoding=rot13", `{"data":"41"}`, http.StatusBadRequest},
+			{"GET", "/insert", ``, http.StatusMethodNotAllowed},
+			{"GET", "/lookup/not-hex", ``, http.StatusBadRequest},
+			{"POST", "/insert", `{"data":"` + strings.Repeat("41", DefaultMaxRequestBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		} {
+			if status := doJSON(t, request.method, testServer.URL+request.path, request.body, nil); status != request.status {
+				t.Errorf("Error: Server: %s %s, Expected: %d, Actual: %d\n", request.method, request.path, request.status, status)
+			}
+		}
+	})
+
+	t.Run("Update and lookup", func(t *testing.T) {
+		response := map[string]string{}
+		status := doJSON(t, "POST", testServer.URL+"/update", `{"hash":"`+hashA+`","data":"5a"}`, &response)
+		if status != http.StatusOK || response["hash"] != hex.EncodeToString(Hash128([]byte("Z"))) {
+			t.Errorf("Error: Server: update: %d %+v\n", status, response)
+		}
+
+		status = doJSON(t, "GET", testServer.URL+"/lookup/"+hashA, "", &response)
+		if status != http.StatusOK || response["data"] != "5a" {
+			t.Errorf("Error: Server: lookup by old hash: %d %+v\n", status, response)
+		}
+
+		if status := doJSON(t, "GET", testServer.URL+"/lookup/00ff", "", nil); status != http.StatusNotFound {
+			t.Errorf("Error: Server: lookup missing hash, Expected: 404, Actual: %d\n", status)
+		}
+		if status := doJSON(t, "POST", testServer.URL+"/update", `{"hash":"00ff","data":"00"}`, nil); status != http.StatusNotFound {
+			t.Errorf("Error: Server: update missing hash, Expected: 404, Actual: %d\n", status)
+		}
+	})
+
+	t.Run("Proof, verify and root", func(t *testing.T) {
+		proof := proofJSON{}
+		if status := doJSON(t, "GET", testServer.URL+"/proof/"+hashA, "", &proof); status != http.StatusOK {
+			t.Fatalf("Error: Server: proof: %d\n", status)
+		}
+
+		root := map[string]any{}
+		doJSON(t, "GET", testServer.URL+"/root", "", &root)
+		if root["root"] != proof.Root || root["leaves"] != 3.0 {
+			t.Errorf("Error: Server: root: %+v\n", root)
+		}
+
+		request, _ := json.Marshal(map[string]any{"proof": proof, "root": proof.Root})
+		verified := map[string]bool{}
+		if status := doJSON(t, "POST", testServer.URL+"/verify", string(request), &verified); status != http.StatusOK || !verified["valid"] {
+			t.Errorf("Error: Server: verify: %d %+v\n", status, verified)
+		}
+
+		request, _ = json.Marshal(map[string]any{"proof": proof, "root": hashA})
+		doJSON(t, "POST", testServer.URL+"/verify", string(request), &verified)
+		if verified["valid"] {
+			t.Errorf("Error: Server: verify against the wrong root succeeded")
+		}
+
+		if status := doJSON(t, "GET", testServer.URL+"/proof/00ff", "", nil); status != http.StatusNotFound {
+			t.Errorf("Error: Server: proof of missing hash, Expected: 404, Actual: %d\n", status)
+		}
+	})
+
+	t.Run("Base64 lookup and proof", func(t *testing.T) {
+		// Find data whose hash has a "/" in standard base64, which would
+		// split the path if the server used that alphabet.
+		data := []byte{}
+		for index := 0; ; index++ {
+			data = []byte(fmt.Sprintf("slash %d", index))
+			if strings.Contains(base64.StdEncoding.EncodeToString(Hash128(data)), "/") {
+				break
+			}
+		}
+		hash := base64.RawURLEncoding.EncodeToString(Hash128(data))
+		body := `{"data":"` + base64.RawURLEncoding.EncodeToString(data) + `"}`
+		if status := doJSON(t, "POST", testServer.URL+"/insert?encoding=base64", body, nil); status != http.StatusCreated {
+			t.Fatalf("Error: Server: base64 insert: %d\n", status)
+		}
+
+		response := map[string]string{}
+		status := doJSON(t, "GET", testServer.URL+"/lookup/"+hash+"?encoding=base64", "", &response)
+		if status != http.StatusOK || response["hash"] != hash || response["data"] != base64.RawURLEncoding.EncodeToString(data) {
+			t.Errorf("Error: Server: base64 lookup of %s: %d %+v\n", hash, status, response)
+		}
+
+		proof := proofJSON{}
+		if status := doJSON(t, "GET", testServer.URL+"/proof/"+hash+"?encoding=base64", "", &proof); status != http.StatusOK || proof.LeafHash != hash {
+			t.Fatalf("Error: Server: base64 proof of %s: %d %+v\n", hash, status, proof)
+		}
+		request, _ := json.Marshal(map[string]any{"proof": proof, "root": proof.Root})
+		verified := map[string]bool{}
+		if status := doJSON(t, "POST", testServer.URL+"/verify?encoding=base64", string(request), &verified); status != http.StatusOK || !verified["valid"] {
+			t.Errorf("Error: Server: base64 verify: %d %+v\n", status, verified)
+		}
+	})
+
+	t.Run("Concurrent inserts", func(t *testing.T) {
+		server := NewServer(InitMerkelTree())
+		done := make(chan int)
+		for worker := 0; worker < 8; worker++ {
+			go func(worker int) {
+				for index := 0; index < 25; index++ {
+					body := `{"data":"` + hex.EncodeToString([]byte{byte(worker), byte(index)}) + `"}`
+					recorder := httptest.NewRecorder()
+					server.ServeHTTP(recorder, httptest.NewRequest("POST", "/insert", bytes.NewBufferString(body)))
+				}
+				done <- worker
+			}(worker)
+		}
+		for worker := 0; worker < 8; worker++ {
+			<-done
+		}
+		if leaves := len(server.tree.Leaves()); leaves != 200 {
+			t.Errorf("Error: Server: concurrent inserts, Expected: 200 leaves, Actual: %d\n", leaves)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+// Sorted mode makes the shape of a MerkelTree a pure function of its set of
+// leaf hashes, so two replicas holding the same records always agree on the
+// root and on every proof, whatever order they were inserted in.
+//
+// Leaves are kept in a crit-bit tree: every branch splits its leaves on the
+// first bit where their hashes differ, zeros on the left. Leaves end up
+// sorted by hash from left to right, and Insert, Update and Delete only
+// touch the path down to one leaf. The depth is about log2 of the number of
+// leaves since the hashes are uniformly distributed.
+//
+// Hashes are domain separated and fixed size, like RFC 6962's, instead of
+// the plain concatenation unsorted trees use:
+//
+//	leaf = Hash128(0x00 || Hash128(data))
+//	node = Hash128(0x01 || left || right)
+//
+// Absence proofs depend on it: with concatenated hashes a forged proof can
+// regroup the bytes of a path into different siblings and still rebuild
+// the root. Proofs from sorted trees have Sorted set.
+
+// InitSortedMerkelTree initializes a new Merkel Tree in sorted mode.
+func InitSortedMerkelTree() *MerkelTree {
+	merkelTree := InitMerkelTree()
+	merkelTree.sorted = true
+	return merkelTree
+}
+
+// Sorted reports whether the tree is in sorted mode.
+func (merkelTree *MerkelTree) Sorted() bool {
+	return merkelTree.sorted
+}
+
+func sortedLeafHash(hash []byte) []byte {
+	return Hash128(append([]byte{0x00}, hash...))
+}
+
+func sortedNodeHash(left, right []byte) []byte {
+	return Hash128(append(append([]byte{0x01}, left...), right...))
+}
+
+// hashBit returns bit index of hash, most significant bit first.
+func hashBit(hash []byte, index int) int {
+	return int(hash[index/8]>>(7-index%8)) & 1
+}
+
+// critBit returns the first bit where two different hashes differ.
+func critBit(hash1, hash2 []byte) int {
+	for index := range hash1 {
+		if diff := hash1[index] ^ hash2[index]; diff != 0 {
+			bit := 0
+			for diff&0x80 == 0 {
+				diff <<= 1
+				bit++
+			}
+			return index*8 + bit
+		}
+	}
+	return len(hash1) * 8
+}
+
+func leftmostLeaf(node *Node) *Node {
+	for node.left != nil {
+		node = node.left
+	}
+	return node
+}
+
+// branchCritBit returns the bit a branch splits its leaves on.
+func branchCritBit(branch *Node) int {
+	return critBit(leftmostLeaf(branch.left).hash, leftmostLeaf(branch.right).hash)
+}
+
+// closestLeaf follows hash's bits down to the leaf sharing the longest
+// prefix with it.
+func (merkelTree *MerkelTree) closestLeaf(hash []byte) *Node {
+	node := merkelTree.root
+	for node != nil && node.left != nil {
+		if hashBit(hash, branchCritBit(node)) == 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+	return node
+}
+
+// insertSorted hangs leaf where its hash belongs. The hash must not be in
+// the tree already.
+func (merkelTree *MerkelTree) insertSorted(leaf *Node) {
+	if merkelTree.root == nil {
+		merkelTree.root = leaf
+		return
+	}
+
+	split := critBit(leaf.hash, merkelTree.closestLeaf(leaf.hash).hash)
+
+	// Go down until the next branch splits on a later bit than the new
+	// leaf does; that subtree becomes the new leaf's sibling.
+	target := merkelTree.root
+	for target.left != nil {
+		bit := branchCritBit(target)
+		if bit > split {
+			break
+		}
+		if hashBit(leaf.hash, bit) == 0 {
+			target = target.left
+		} else {
+			target = target.right
+		}
+	}
+
+	parent := target.prev
+	branch := &Node{data: []byte("X"), prev: parent}
+	if hashBit(leaf.hash, split) == 0 {
+		branch.left, branch.right = leaf, target
+	} else {
+		branch.left, branch.right = target, leaf
+	}
+	if parent == nil {
+		merkelTree.root = branch
+		branch.data = []byte("Y")
+		if target.left != nil {
+			target.data = []byte("X")
+		}
+	} else if parent.left == target {
+		parent.left = branch
+	} else {
+		parent.right = branch
+	}
+	target.prev, leaf.prev = branch, branch
+
+	merkelTree.rehash(branch)
+}
+
+// updateSorted gives leaf new data and moves it to where its new hash
+// belongs. Two leaves can't share a hash in sorted mode, so updating to the
+// hash of another leaf returns ErrHashExists and changes nothing.
+func (merkelTree *MerkelTree) updateSorted(leaf *Node, data, hash []byte) error {
+	merkelTree.detach(leaf)
+	if closest := merkelTree.closestLeaf(hash); closest != nil && compareHash(closest.hash, hash) {
+		merkelTree.insertSorted(leaf)
+		return ErrHashExists
+	}
+
+	leaf.data = data
+	leaf.hash = hash
+	merkelTree.insertSorted(leaf)
+	return nil
+}
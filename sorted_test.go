@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// sortedShape renders a tree with hashes so two trees compare equal only
+// when their shapes match too.
+func sortedShape(t *testing.T, tree *MerkelTree) string {
+	var out bytes.Buffer
+	if err := tree.Render(&out, RenderOptions{ShowHash: true}); err != nil {
+		t.Fatalf("Error: Render: %+v\n", err)
+	}
+	return out.String()
+}
+
+func sortedTreeOf(data []string) *MerkelTree {
+	tree := InitSortedMerkelTree()
+	for _, item := range data {
+		tree.Insert([]byte(item))
+	}
+	return tree
+}
+
+func Test_SortedMerkelTree(t *testing.T) {
+	data := []string{}
+	for index := 0; index < 40; index++ {
+		data = append(data, fmt.Sprintf("record %d", index))
+	}
+	reference := sortedTreeOf(data)
+
+	t.Run("Shape only depends on the set", func(t *testing.T) {
+		random := rand.New(rand.NewSource(7))
+		for round := 0; round < 5; round++ {
+			shuffled := []string{}
+			for _, index := range random.Perm(len(data)) {
+				shuffled = append(shuffled, data[index])
+			}
+			if sortedShape(t, sortedTreeOf(shuffled)) != sortedShape(t, reference) {
+				t.Fatalf("Error: Insert: insertion order changed the shape")
+			}
+		}
+	})
+
+	t.Run("Leaves are sorted by hash", func(t *testing.T) {
+		leaves := reference.Leaves()
+		if len(leaves) != len(data) {
+			t.Fatalf("Error: Leaves: Expected: %d, Actual: %d\n", len(data), len(leaves))
+		}
+		for index := 1; index < len(leaves); index++ {
+			if bytes.Compare(leaves[index-1].hash, leaves[index].hash) >= 0 {
+				t.Errorf("Error: Leaves: leaf %d is out of order\n", index)
+			}
+		}
+		for _, item := range data {
+			proof, err := reference.GenerateProof(Hash128([]byte(item)))
+			if err != nil || !proof.Sorted || !VerifyProof(proof, reference.rootHash()) {
+				t.Errorf("Error: VerifyProof: %s rejected\n", item)
+			}
+		}
+		if len(reference.rootHash()) != hashSize {
+			t.Errorf("Error: rootHash: Expected: %d bytes, Actual: %d\n", hashSize, len(reference.rootHash()))
+		}
+	})
+
+	t.Run("Update and Delete keep the invariant", func(t *testing.T) {
+		tree := sortedTreeOf(data)
+		hash7 := Hash128([]byte("record 7"))
+		if _, err := tree.Update([]byte("renamed 7"), hash7); err != nil {
+			t.Fatalf("Error: Update: %+v\n", err)
+		}
+		if err := tree.Delete(Hash128([]byte("record 12"))); err != nil {
+			t.Fatalf("Error: Delete: %+v\n", err)
+		}
+
+		expected := []string{}
+		for _, item := range data {
+			switch item {
+			case "record 7":
+				expected = append(expected, "renamed 7")
+			case "record 12":
+			default:
+				expected = append(expected, item)
+			}
+		}
+		if sortedShape(t, tree) != sortedShape(t, sortedTreeOf(expected)) {
+			t.Errorf("Error: Update: shape differs from a tree built from the same set")
+		}
+
+		// The old hash still finds the updated leaf.
+		node, err := tree.Lookup(hash7)
+		if err != nil || string(node.data) != "renamed 7" {
+			t.Errorf("Error: Lookup: old hash lost after moving the leaf")
+		}
+		proof, err := tree.GenerateProof(Hash128([]byte("renamed 7")))
+		if err != nil || !VerifyProof(proof, tree.root.hash) {
+			t.Errorf("Error: VerifyProof: moved leaf rejected")
+		}
+	})
+
+	t.Run("Update to another leaf's hash", func(t *testing.T) {
+		tree := sortedTreeOf(data)
+		before := sortedShape(t, tree)
+		if _, err := tree.Update([]byte("record 3"), Hash128([]byte("record 4"))); !errors.Is(err, ErrHashExists) {
+			t.Errorf("Error: Update: Expected: %v, Actual: %v\n", ErrHashExists, err)
+		}
+		if sortedShape(t, tree) != before {
+			t.Errorf("Error: Update: rejected update changed the tree")
+		}
+	})
+
+	t.Run("Sorted mode survives Save and Load", func(t *testing.T) {
+		var saved bytes.Buffer
+		reference.Save(&saved)
+		loaded, err := LoadMerkelTree(&saved)
+		if err != nil || !loaded.Sorted() {
+			t.Fatalf("Error: LoadMerkelTree: sorted mode lost: %v\n", err)
+		}
+		loaded.Insert([]byte("record 40"))
+		if sortedShape(t, loaded) != sortedShape(t, sortedTreeOf(append(data, "record 40"))) {
+			t.Errorf("Error: LoadMerkelTree: insert after load isn't sorted")
+		}
+		if InitMerkelTree().Sorted() {
+			t.Errorf("Error: Sorted: default tree reports sorted mode")
+		}
+	})
+}
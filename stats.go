@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// TreeStats summarises the shape and size of a merkel tree.
+type TreeStats struct {
+	LeafCount        int
+	InteriorCount    int
+	MinLeafDepth     int
+	MaxLeafDepth     int
+	AverageLeafDepth float64
+	// BalanceFactor is the difference between the deepest and the
+	// shallowest leaf, 0 for a perfectly balanced tree.
+	BalanceFactor int
+	// PayloadBytes is the total size of the data stored in the leaves.
+	PayloadBytes int
+	// HistoryEntries is the number of hashes kept in hashUpdateHistroy
+	// across every lookup entry.
+	HistoryEntries int
+	// ApproxMemoryBytes estimates the memory held by the nodes, their
+	// hashes and data, and the lookup list.
+	ApproxMemoryBytes int
+}
+
+// Stats walks the tree and the lookup list and reports their size and shape.
+func (merkelTree *MerkelTree) Stats() TreeStats {
+	stats := TreeStats{}
+	totalDepth := 0
+
+	for node, depth := range merkelTree.Nodes(PreOrder) {
+		stats.ApproxMemoryBytes += int(unsafe.Sizeof(*node)) + cap(node.hash) + cap(node.data)
+
+		if node.left != nil || node.right != nil {
+			stats.InteriorCount++
+			continue
+		}
+
+		if stats.LeafCount == 0 || depth < stats.MinLeafDepth {
+			stats.MinLeafDepth = depth
+		}
+		if depth > stats.MaxLeafDepth {
+			stats.MaxLeafDepth = depth
+		}
+		stats.LeafCount++
+		totalDepth += depth
+		stats.PayloadBytes += len(node.data)
+	}
+
+	for key, mapping := range merkelTree.lookupNodeList {
+		stats.ApproxMemoryBytes += len(key) + int(unsafe.Sizeof(*mapping))
+		stats.HistoryEntries += len(mapping.hashUpdateHistroy)
+		for _, hash := range mapping.hashUpdateHistroy {
+			stats.ApproxMemoryBytes += cap(hash)
+		}
+	}
+
+	if stats.LeafCount > 0 {
+		stats.AverageLeafDepth = float64(totalDepth) / float64(stats.LeafCount)
+	}
+	stats.BalanceFactor = stats.MaxLeafDepth - stats.MinLeafDepth
+
+	return stats
+}
+
+// String formats the statistics as a small report, one value per line.
+func (stats TreeStats) String() string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "leaves:           %d\n", stats.LeafCount)
+	fmt.Fprintf(&report, "interior nodes:   %d\n", stats.InteriorCount)
+	fmt.Fprintf(&report, "leaf depth:       min %d, max %d, avg %.2f\n",
+		stats.MinLeafDepth, stats.MaxLeafDepth, stats.AverageLeafDepth)
+	fmt.Fprintf(&report, "balance factor:   %d\n", stats.BalanceFactor)
+	fmt.Fprintf(&report, "payload bytes:    %d\n", stats.PayloadBytes)
+	fmt.Fprintf(&report, "history entries:  %d\n", stats.HistoryEntries)
+	fmt.Fprintf(&report, "approx memory:    %d bytes\n", stats.ApproxMemoryBytes)
+	return report.String()
+}
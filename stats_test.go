@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Stats(t *testing.T) {
+	t.Run("Stats on a 5 leaf tree with history", func(t *testing.T) {
+		//   The tree
+		//               Y
+		//             /   \
+		//            X     X
+		//          /  \   /  \
+		//         X    A D    B
+		//       /  \
+		//      E    C
+		testMerkelTree := InitMerkelTree()
+		for _, data := range []string{"A", "B", "C", "D", "E"} {
+			testMerkelTree.Insert([]byte(data))
+		}
+		hashF, _ := testMerkelTree.Update([]byte("FF"), Hash128([]byte("A")))
+		testMerkelTree.Update([]byte("GGG"), hashF)
+
+		stats := testMerkelTree.Stats()
+		if stats.LeafCount != 5 || stats.InteriorCount != 4 {
+			t.Errorf("Error: Stats: node counts, Expected: 5/4, Actual: %d/%d\n", stats.LeafCount, stats.InteriorCount)
+		}
+		if stats.MinLeafDepth != 2 || stats.MaxLeafDepth != 3 || stats.BalanceFactor != 1 {
+			t.Errorf("Error: Stats: depths, Expected: 2/3/1, Actual: %d/%d/%d\n",
+				stats.MinLeafDepth, stats.MaxLeafDepth, stats.BalanceFactor)
+		}
+		if stats.AverageLeafDepth != 12.0/5.0 {
+			t.Errorf("Error: Stats: average depth, Expected: 2.4, Actual: %f\n", stats.AverageLeafDepth)
+		}
+		// E, C, GGG, D, B
+		if stats.PayloadBytes != 7 {
+			t.Errorf("Error: Stats: payload bytes, Expected: 7, Actual: %d\n", stats.PayloadBytes)
+		}
+		if stats.HistoryEntries != 2 {
+			t.Errorf("Error: Stats: history entries, Expected: 2, Actual: %d\n", stats.HistoryEntries)
+		}
+		if stats.ApproxMemoryBytes <= 0 {
+			t.Errorf("Error: Stats: memory estimate missing")
+		}
+		if !strings.Contains(stats.String(), "balance factor:   1") {
+			t.Errorf("Error: Stats: report missing balance factor:\n%s\n", stats.String())
+		}
+	})
+
+	t.Run("Stats on an empty tree", func(t *testing.T) {
+		stats := InitMerkelTree().Stats()
+		if stats != (TreeStats{}) {
+			t.Errorf("Error: Stats: expected zero stats, Actual: %+v\n", stats)
+		}
+	})
+}
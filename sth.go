@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TreeHeadHashAlgorithm names the hash behind MerkelTree roots: SHA-256
+// truncated to 128 bits by Hash128.
+const TreeHeadHashAlgorithm = "sha256-128"
+
+// LogHeadHashAlgorithm names the hash behind MerkelLog roots: full SHA-256,
+// as in RFC 6962.
+const LogHeadHashAlgorithm = "sha256"
+
+// SignedTreeHead commits the operator to a root hash at a given tree size
+// and time. Handing one out makes the root accountable; a client holding
+// two contradicting tree heads can prove the operator misbehaved.
+type SignedTreeHead struct {
+	TreeSize uint64 `json:"tree_size"`
+	RootHash []byte `json:"root_hash"`
+	// Timestamp is in milliseconds since the Unix epoch, like CT.
+	Timestamp     int64  `json:"timestamp"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Signature     []byte `json:"signature"`
+}
+
+// signedMessage is the exact byte string covered by the signature. Every
+// variable length field is length prefixed so fields can't bleed into each
+// other.
+func (sth *SignedTreeHead) signedMessage() []byte {
+	message := []byte("merkel tree head v1\n")
+	message = binary.BigEndian.AppendUint16(message, uint16(len(sth.HashAlgorithm)))
+	message = append(message, sth.HashAlgorithm...)
+	message = binary.BigEndian.AppendUint64(message, sth.TreeSize)
+	message = binary.BigEndian.AppendUint64(message, uint64(sth.Timestamp))
+	message = binary.BigEndian.AppendUint32(message, uint32(len(sth.RootHash)))
+	return append(message, sth.RootHash...)
+}
+
+// SignRoot signs root as the root hash of a MerkelTree of the given size.
+func SignRoot(key ed25519.PrivateKey, size uint64, root []byte, timestamp time.Time) (*SignedTreeHead, error) {
+	return signRoot(key, TreeHeadHashAlgorithm, size, root, timestamp)
+}
+
+// SignLogRoot signs root as the root hash of a MerkelLog of the given size.
+func SignLogRoot(key ed25519.PrivateKey, size uint64, root []byte, timestamp time.Time) (*SignedTreeHead, error) {
+	return signRoot(key, LogHeadHashAlgorithm, size, root, timestamp)
+}
+
+func signRoot(key ed25519.PrivateKey, algorithm string, size uint64, root []byte, timestamp time.Time) (*SignedTreeHead, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+	if len(root) > 0xffffffff {
+		return nil, errors.New("root hash too long")
+	}
+
+	sth := &SignedTreeHead{
+		TreeSize:      size,
+		RootHash:      append([]byte{}, root...),
+		Timestamp:     timestamp.UnixMilli(),
+		HashAlgorithm: algorithm,
+	}
+	sth.Signature = ed25519.Sign(key, sth.signedMessage())
+
+	return sth, nil
+}
+
+// VerifySignedTreeHead checks the tree head's signature against key.
+func VerifySignedTreeHead(key ed25519.PublicKey, sth *SignedTreeHead) error {
+	if sth == nil {
+		return errors.New("no tree head")
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid ed25519 public key")
+	}
+	if sth.HashAlgorithm != TreeHeadHashAlgorithm && sth.HashAlgorithm != LogHeadHashAlgorithm {
+		return fmt.Errorf("unsupported hash algorithm %q", sth.HashAlgorithm)
+	}
+	if !ed25519.Verify(key, sth.signedMessage(), sth.Signature) {
+		return errors.New("invalid tree head signature")
+	}
+
+	return nil
+}
+
+// SignRoot signs the tree's current root. The tree size is its leaf count.
+func (merkelTree *MerkelTree) SignRoot(key ed25519.PrivateKey, timestamp time.Time) (*SignedTreeHead, error) {
+	return SignRoot(key, uint64(len(merkelTree.Leaves())), merkelTree.rootHash(), timestamp)
+}
+
+// SignRoot signs the log's current root.
+func (merkelLog *MerkelLog) SignRoot(key ed25519.PrivateKey, timestamp time.Time) (*SignedTreeHead, error) {
+	return SignLogRoot(key, merkelLog.Size(), merkelLog.RootHash(), timestamp)
+}
+
+// VerifyProofWithTreeHead checks the tree head's signature and then that
+// proof rebuilds its root.
+func VerifyProofWithTreeHead(proof *MerkelProof, sth *SignedTreeHead, key ed25519.PublicKey) error {
+	if err := VerifySignedTreeHead(key, sth); err != nil {
+		return err
+	}
+	if sth.HashAlgorithm != TreeHeadHashAlgorithm {
+		return fmt.Errorf("tree head is for a %s root, not a merkel tree", sth.HashAlgorithm)
+	}
+	if !VerifyProof(proof, sth.RootHash) {
+		return errors.New("proof doesn't match the signed root")
+	}
+	return nil
+}
+
+// VerifyInclusionWithTreeHead checks the tree head's signature and then that
+// the log entry with leafHash at index is included in it.
+func VerifyInclusionWithTreeHead(leafHash []byte, index uint64, proof [][]byte, sth *SignedTreeHead, key ed25519.PublicKey) error {
+	if err := VerifySignedTreeHead(key, sth); err != nil {
+		return err
+	}
+	if sth.HashAlgorithm != LogHeadHashAlgorithm {
+		return fmt.Errorf("tree head is for a %s root, not a log", sth.HashAlgorithm)
+	}
+	return VerifyInclusion(leafHash, index, sth.TreeSize, proof, sth.RootHash)
+}
+
+// EncodePrivateKeyPEM encodes key as a PKCS #8 "PRIVATE KEY" PEM block.
+func EncodePrivateKeyPEM(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodePublicKeyPEM encodes key as a PKIX "PUBLIC KEY" PEM block.
+func EncodePublicKeyPEM(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKeyPEM parses an Ed25519 key from a PKCS #8 PEM block, the
+// format written by EncodePrivateKeyPEM and `openssl genpkey -algorithm ed25519`.
+func ParsePrivateKeyPEM(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("no PRIVATE KEY PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an ed25519 key, found %T", key)
+	}
+	return edKey, nil
+}
+
+// ParsePublicKeyPEM parses an Ed25519 key from a PKIX PEM block.
+func ParsePublicKeyPEM(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PUBLIC KEY PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an ed25519 key, found %T", key)
+	}
+	return edKey, nil
+}
+
+// LoadPrivateKeyPEM reads an Ed25519 private key from a PEM file.
+func LoadPrivateKeyPEM(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(data)
+}
+
+// LoadPublicKeyPEM reads an Ed25519 public key from a PEM file.
+func LoadPublicKeyPEM(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKeyPEM(data)
+}
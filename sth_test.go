@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKey derives a fixed Ed25519 key from seed so tests are repeatable.
+func testKey(seed byte) ed25519.PrivateKey {
+	keySeed := make([]byte, ed25519.SeedSize)
+	for index := range keySeed {
+		keySeed[index] = seed
+	}
+	return ed25519.NewKeyFromSeed(keySeed)
+}
+
+func Test_SignedTreeHead(t *testing.T) {
+	key := testKey(1)
+	publicKey := key.Public().(ed25519.PublicKey)
+	timestamp := time.UnixMilli(1700000000000)
+
+	testMerkelTree := InitMerkelTree()
+	for _, data := range []string{"A", "B", "C", "D"} {
+		testMerkelTree.Insert([]byte(data))
+	}
+
+	t.Run("Sign and verify the tree root", func(t *testing.T) {
+		sth, err := testMerkelTree.SignRoot(key, timestamp)
+		if err != nil {
+			t.Fatalf("Error: SignRoot: %+v\n", err)
+		}
+		if sth.TreeSize != 4 || sth.Timestamp != 1700000000000 || sth.HashAlgorithm != TreeHeadHashAlgorithm {
+			t.Errorf("Error: SignRoot: unexpected tree head %+v\n", sth)
+		}
+		if err := VerifySignedTreeHead(publicKey, sth); err != nil {
+			t.Errorf("Error: VerifySignedTreeHead: %+v\n", err)
+		}
+
+		proof, _ := testMerkelTree.GenerateProof(Hash128([]byte("C")))
+		if err := VerifyProofWithTreeHead(proof, sth, publicKey); err != nil {
+			t.Errorf("Error: VerifyProofWithTreeHead: %+v\n", err)
+		}
+	})
+
+	t.Run("Any change breaks the signature", func(t *testing.T) {
+		sth, _ := testMerkelTree.SignRoot(key, timestamp)
+		for name, tamper := range map[string]func(*SignedTreeHead){
+			"size":      func(sth *SignedTreeHead) { sth.TreeSize++ },
+			"timestamp": func(sth *SignedTreeHead) { sth.Timestamp++ },
+			"root":      func(sth *SignedTreeHead) { sth.RootHash[0] ^= 1 },
+			"algorithm": func(sth *SignedTreeHead) { sth.HashAlgorithm = "sha256" },
+		} {
+			tampered := *sth
+			tampered.RootHash = append([]byte{}, sth.RootHash...)
+			tamper(&tampered)
+			if VerifySignedTreeHead(publicKey, &tampered) == nil {
+				t.Errorf("Error: VerifySignedTreeHead: tampered %s accepted\n", name)
+			}
+		}
+		otherKey := testKey(2).Public().(ed25519.PublicKey)
+		if VerifySignedTreeHead(otherKey, sth) == nil {
+			t.Errorf("Error: VerifySignedTreeHead: wrong key accepted")
+		}
+	})
+
+	t.Run("PEM round trip", func(t *testing.T) {
+		dir := t.TempDir()
+		privatePEM, _ := EncodePrivateKeyPEM(key)
+		publicPEM, _ := EncodePublicKeyPEM(publicKey)
+		os.WriteFile(filepath.Join(dir, "key.pem"), privatePEM, 0o600)
+		os.WriteFile(filepath.Join(dir, "key.pub"), publicPEM, 0o644)
+
+		loadedKey, err := LoadPrivateKeyPEM(filepath.Join(dir, "key.pem"))
+		if err != nil || !loadedKey.Equal(key) {
+			t.Errorf("Error: LoadPrivateKeyPEM: %+v\n", err)
+		}
+		loadedPublicKey, err := LoadPublicKeyPEM(filepath.Join(dir, "key.pub"))
+		if err != nil || !loadedPublicKey.Equal(publicKey) {
+			t.Errorf("Error: LoadPublicKeyPEM: %+v\n", err)
+		}
+		if _, err := ParsePrivateKeyPEM(publicPEM); err == nil {
+			t.Errorf("Error: ParsePrivateKeyPEM: public key accepted")
+		}
+		if _, err := LoadPublicKeyPEM(filepath.Join(dir, "missing")); err == nil {
+			t.Errorf("Error: LoadPublicKeyPEM: missing file accepted")
+		}
+	})
+
+	t.Run("Log server signs its tree heads", func(t *testing.T) {
+		logServer := NewLogServer(NewMerkelLog())
+		logServer.Signer = key
+		testServer := httptest.NewServer(logServer)
+		defer testServer.Close()
+		client := &ctClient{t: t, url: testServer.URL}
+		client.addEntry("artifact")
+		added := client.addEntry("signed artifact")
+
+		response := getSTHResponse{}
+		client.get("/ct/v1/get-sth", &response)
+		sth := response.signedTreeHead()
+		if err := VerifySignedTreeHead(publicKey, sth); err != nil {
+			t.Fatalf("Error: LogServer: signed get-sth: %+v\n", err)
+		}
+
+		if sth.HashAlgorithm != LogHeadHashAlgorithm || len(sth.RootHash) != 32 {
+			t.Errorf("Error: LogServer: Expected: a 32 byte %s root, Actual: %d byte %s root\n", LogHeadHashAlgorithm, len(sth.RootHash), sth.HashAlgorithm)
+		}
+
+		proof, _ := logServer.log.InclusionProof(added.LeafIndex, sth.TreeSize)
+		if err := VerifyInclusionWithTreeHead(added.LeafHash, added.LeafIndex, proof, sth, publicKey); err != nil {
+			t.Errorf("Error: VerifyInclusionWithTreeHead: %+v\n", err)
+		}
+
+		// A tree head only vouches for the kind of root it was signed as.
+		treeHead, _ := testMerkelTree.SignRoot(key, timestamp)
+		if VerifyInclusionWithTreeHead(added.LeafHash, added.LeafIndex, proof, treeHead, publicKey) == nil {
+			t.Errorf("Error: VerifyInclusionWithTreeHead: merkel tree head accepted for a log proof")
+		}
+		treeProof, _ := testMerkelTree.GenerateProof(Hash128([]byte("C")))
+		if VerifyProofWithTreeHead(treeProof, sth, publicKey) == nil {
+			t.Errorf("Error: VerifyProofWithTreeHead: log tree head accepted for a merkel tree proof")
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// A verified stream interleaves every chunk of a ChunkedFile with the
+// sibling hashes needed to check it against the root. Each chunk is sent as
+// one frame:
+//
+//	uint32  number of proof hashes
+//	per proof hash, leaf to root:
+//	  uint32  hash length
+//	  []byte  hash
+//	uint32  chunk length
+//	[]byte  chunk
+//
+// All integers are big endian. The hashes are the ChunkProof siblings; the
+// receiver knows the chunk index and count, which fix the directions.
+
+// EncodeVerifiedStream writes the verified stream for the content of r, which
+// must be the same content chunked was built from.
+func EncodeVerifiedStream(w io.Writer, chunked *ChunkedFile, r io.Reader) error {
+	buffer := make([]byte, chunked.ChunkSize)
+	for index := 0; index < chunked.Chunks(); index++ {
+		read, err := io.ReadFull(r, buffer)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		chunk := buffer[:read]
+
+		proof, err := chunked.ProveChunk(index)
+		if err != nil {
+			return err
+		}
+		// Catch a mismatched reader here rather than on the receiving end.
+		if err := VerifyChunk(chunked.Root(), index, chunked.Chunks(), chunk, proof); err != nil {
+			return fmt.Errorf("content doesn't match the chunked file: %w", err)
+		}
+
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(proof.Siblings)))
+		for _, hash := range proof.Siblings {
+			frame = binary.BigEndian.AppendUint32(frame, uint32(len(hash)))
+			frame = append(frame, hash...)
+		}
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(chunk)))
+		frame = append(frame, chunk...)
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyingReader decodes a verified stream. Each chunk is checked against
+// the trusted root as soon as its frame has arrived and only then handed to
+// the caller, so a corrupted chunk is rejected before any of its bytes are
+// released and nothing after it is read.
+type VerifyingReader struct {
+	r         io.Reader
+	root      []byte
+	chunks    int
+	chunkSize int
+	index     int
+	pending   []byte
+	err       error
+}
+
+// NewVerifyingReader returns a reader releasing the verified content of the
+// stream in r. root, chunks and chunkSize must come from a trusted source,
+// e.g. the ChunkedFile the stream was encoded from; the chunk count is what
+// stops a truncated stream from passing as complete.
+func NewVerifyingReader(r io.Reader, root []byte, chunks, chunkSize int) *VerifyingReader {
+	return &VerifyingReader{
+		r:         r,
+		root:      root,
+		chunks:    chunks,
+		chunkSize: chunkSize,
+	}
+}
+
+// Read implements io.Reader. Once a chunk fails verification every later
+// call returns the same error.
+func (reader *VerifyingReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		if reader.index == reader.chunks {
+			reader.err = reader.checkTrailing()
+			continue
+		}
+		reader.pending, reader.err = reader.nextChunk()
+	}
+
+	read := copy(p, reader.pending)
+	reader.pending = reader.pending[read:]
+	return read, nil
+}
+
+// nextChunk reads and verifies the next frame.
+func (reader *VerifyingReader) nextChunk() ([]byte, error) {
+	index := reader.index
+	fail := func(err error) ([]byte, error) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("chunk %d: %w", index, err)
+	}
+
+	count, err := reader.readUint32()
+	if err != nil {
+		return fail(err)
+	}
+	// Every proof hash is a node hash as long as the root, and a proof
+	// holds one sibling per level. This bounds what a hostile stream can
+	// make us allocate.
+	if int(count) > bits.Len(uint(reader.chunks)) {
+		return fail(errors.New("proof is deeper than the tree"))
+	}
+
+	proof := &ChunkProof{}
+	for position := 0; position < int(count); position++ {
+		length, err := reader.readUint32()
+		if err != nil {
+			return fail(err)
+		}
+		if int(length) != len(reader.root) {
+			return fail(fmt.Errorf("invalid proof hash length %d", length))
+		}
+		hash := make([]byte, length)
+		if _, err := io.ReadFull(reader.r, hash); err != nil {
+			return fail(err)
+		}
+		proof.Siblings = append(proof.Siblings, hash)
+	}
+
+	length, err := reader.readUint32()
+	if err != nil {
+		return fail(err)
+	}
+	if int(length) > reader.chunkSize || length == 0 {
+		return fail(fmt.Errorf("invalid chunk length %d", length))
+	}
+	if int(length) != reader.chunkSize && index != reader.chunks-1 {
+		return fail(errors.New("short chunk before the end of the stream"))
+	}
+	chunk := make([]byte, length)
+	if _, err := io.ReadFull(reader.r, chunk); err != nil {
+		return fail(err)
+	}
+
+	if err := VerifyChunk(reader.root, index, reader.chunks, chunk, proof); err != nil {
+		return nil, err
+	}
+	reader.index++
+
+	return chunk, nil
+}
+
+// checkTrailing makes sure nothing follows the last chunk.
+func (reader *VerifyingReader) checkTrailing() error {
+	extra := make([]byte, 1)
+	for {
+		read, err := reader.r.Read(extra)
+		if read > 0 {
+			return errors.New("unexpected data after the last chunk")
+		}
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (reader *VerifyingReader) readUint32() (uint32, error) {
+	var buffer [4]byte
+	if _, err := io.ReadFull(reader.r, buffer[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buffer[:]), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func Test_VerifyingReader(t *testing.T) {
+	file := []byte{}
+	for index := 0; index < 300; index++ {
+		file = append(file, byte(index), byte(index>>8), 'x')
+	}
+	chunked, _ := HashChunks(bytes.NewReader(file), 128)
+
+	var stream bytes.Buffer
+	if err := EncodeVerifiedStream(&stream, chunked, bytes.NewReader(file)); err != nil {
+		t.Fatalf("Error: EncodeVerifiedStream: %+v\n", err)
+	}
+	encoded := stream.Bytes()
+
+	t.Run("Round trip", func(t *testing.T) {
+		reader := NewVerifyingReader(bytes.NewReader(encoded), chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Error: VerifyingReader: %+v\n", err)
+		}
+		if !bytes.Equal(decoded, file) {
+			t.Errorf("Error: VerifyingReader: decoded content mismatch")
+		}
+	})
+
+	t.Run("Corrupted chunk fails before its bytes are released", func(t *testing.T) {
+		corrupted := append([]byte{}, encoded...)
+		// The last byte of the stream belongs to the last chunk.
+		corrupted[len(corrupted)-1] ^= 0xff
+
+		reader := NewVerifyingReader(bytes.NewReader(corrupted), chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		decoded, err := io.ReadAll(reader)
+		if err == nil {
+			t.Fatalf("Error: VerifyingReader: corrupted chunk accepted")
+		}
+		lastChunk := (chunked.Chunks() - 1) * chunked.ChunkSize
+		if !bytes.Equal(decoded, file[:lastChunk]) {
+			t.Errorf("Error: VerifyingReader: Expected the %d verified bytes, Actual: %d\n", lastChunk, len(decoded))
+		}
+		if _, again := reader.Read(make([]byte, 1)); again == nil || again.Error() != err.Error() {
+			t.Errorf("Error: VerifyingReader: error isn't sticky")
+		}
+	})
+
+	t.Run("Chunks from another stream are rejected", func(t *testing.T) {
+		other := append([]byte{}, file...)
+		other[0] = 'Z'
+		otherChunked, _ := HashChunks(bytes.NewReader(other), 128)
+		var otherStream bytes.Buffer
+		EncodeVerifiedStream(&otherStream, otherChunked, bytes.NewReader(other))
+
+		reader := NewVerifyingReader(&otherStream, chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		decoded, err := io.ReadAll(reader)
+		if err == nil || len(decoded) != 0 {
+			t.Errorf("Error: VerifyingReader: foreign stream accepted")
+		}
+	})
+
+	t.Run("Truncated and padded streams", func(t *testing.T) {
+		reader := NewVerifyingReader(bytes.NewReader(encoded[:len(encoded)-10]), chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		if _, err := io.ReadAll(reader); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Error: VerifyingReader: truncated stream, Expected: %v, Actual: %v\n", io.ErrUnexpectedEOF, err)
+		}
+
+		padded := append(append([]byte{}, encoded...), 0)
+		reader = NewVerifyingReader(bytes.NewReader(padded), chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Errorf("Error: VerifyingReader: trailing data accepted")
+		}
+	})
+
+	t.Run("Oversized hash lengths are rejected", func(t *testing.T) {
+		hostile := []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}
+		reader := NewVerifyingReader(bytes.NewReader(hostile), chunked.Root(), chunked.Chunks(), chunked.ChunkSize)
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Errorf("Error: VerifyingReader: oversized hash accepted")
+		}
+	})
+
+	t.Run("Encoder rejects mismatched content", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := EncodeVerifiedStream(&out, chunked, bytes.NewReader(file[1:])); err == nil {
+			t.Errorf("Error: EncodeVerifiedStream: mismatched content accepted")
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import "encoding/binary"
+
+// SumTree is the Merkle sum tree variant of MerkelTree used for proofs of
+// liabilities: every leaf carries an amount and every branch commits to
+// the hashes and the sums of both its children, so the root commits to the
+// total. A user given a proof can check their own amount is included and
+// that every sum on the way up adds up, without learning other amounts
+// beyond the sibling sums. Amounts are unsigned and sums are checked for
+// overflow, so no subtree can carry a negative balance.
+//
+// It's an annotated MerkelTree with SumAggregate, so amounts and sums are
+// hashed as big endian uint64s.
+type SumTree struct {
+	tree *MerkelTree
+}
+
+// SumProofStep is a sibling on the way from a leaf to the root.
+type SumProofStep struct {
+	Hash []byte
+	Sum  uint64
+	// Left is true when the sibling is the left child.
+	Left bool
+}
+
+// SumProof proves a leaf and its amount are part of a SumTree.
+type SumProof struct {
+	LeafHash  []byte
+	Amount    uint64
+	ProofList []SumProofStep
+}
+
+// NewSumTree creates an empty sum tree.
+func NewSumTree() *SumTree {
+	return &SumTree{tree: InitAnnotatedMerkelTree(SumAggregate)}
+}
+
+// RootHash returns the root hash, nil for an empty tree.
+func (tree *SumTree) RootHash() []byte {
+	return tree.tree.rootHash()
+}
+
+// Total returns the sum of every amount in the tree.
+func (tree *SumTree) Total() uint64 {
+	total := tree.tree.RootAnnotation()
+	if total == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(total)
+}
+
+// Insert adds data, e.g. an account ID, with its amount and returns the
+// leaf hash, Hash128(data).
+func (tree *SumTree) Insert(data []byte, amount uint64) ([]byte, error) {
+	return tree.tree.InsertAnnotated(data, binary.BigEndian.AppendUint64(nil, amount))
+}
+
+// Update changes the amount of the leaf with hash leafHash.
+func (tree *SumTree) Update(leafHash []byte, amount uint64) error {
+	return tree.tree.UpdateAnnotation(leafHash, binary.BigEndian.AppendUint64(nil, amount))
+}
+
+// GenerateProof returns the proof for the leaf with hash leafHash.
+func (tree *SumTree) GenerateProof(leafHash []byte) (*SumProof, error) {
+	annotated, err := tree.tree.GenerateAnnotatedProof(leafHash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &SumProof{LeafHash: annotated.LeafHash, Amount: binary.BigEndian.Uint64(annotated.Annotation), ProofList: []SumProofStep{}}
+	for _, step := range annotated.ProofList {
+		proof.ProofList = append(proof.ProofList, SumProofStep{Hash: step.Hash, Sum: binary.BigEndian.Uint64(step.Annotation), Left: step.Left})
+	}
+	return proof, nil
+}
+
+// VerifySumProof checks that the proof rebuilds rootHash and that the sums
+// along the way add up to total without overflowing.
+func VerifySumProof(proof *SumProof, rootHash []byte, total uint64) bool {
+	if proof == nil {
+		return false
+	}
+
+	annotated := &AnnotatedProof{LeafHash: proof.LeafHash, Annotation: binary.BigEndian.AppendUint64(nil, proof.Amount)}
+	for _, step := range proof.ProofList {
+		annotated.ProofList = append(annotated.ProofList, AnnotatedProofStep{Hash: step.Hash, Annotation: binary.BigEndian.AppendUint64(nil, step.Sum), Left: step.Left})
+	}
+	return VerifyAnnotatedProof(SumAggregate, annotated, rootHash, binary.BigEndian.AppendUint64(nil, total))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func Test_SumTree(t *testing.T) {
+	tree := NewSumTree()
+	total := uint64(0)
+	for index := 0; index < 11; index++ {
+		amount := uint64(index * 100)
+		if _, err := tree.Insert([]byte(fmt.Sprintf("account %d", index)), amount); err != nil {
+			t.Fatalf("Error: Insert: %+v\n", err)
+		}
+		total += amount
+	}
+
+	t.Run("Total and proofs", func(t *testing.T) {
+		if tree.Total() != total {
+			t.Errorf("Error: Total: Expected: %d, Actual: %d\n", total, tree.Total())
+		}
+		for index := 0; index < 11; index++ {
+			proof, err := tree.GenerateProof(Hash128([]byte(fmt.Sprintf("account %d", index))))
+			if err != nil {
+				t.Fatalf("Error: GenerateProof: %+v\n", err)
+			}
+			if proof.Amount != uint64(index*100) || !VerifySumProof(proof, tree.RootHash(), total) {
+				t.Errorf("Error: VerifySumProof: account %d rejected\n", index)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		hash := Hash128([]byte("account 3"))
+		oldRoot := tree.RootHash()
+		if err := tree.Update(hash, 1000); err != nil {
+			t.Fatalf("Error: Update: %+v\n", err)
+		}
+		total += 700
+		if tree.Total() != total || compareHash(oldRoot, tree.RootHash()) {
+			t.Errorf("Error: Update: Expected: total %d and a new root, Actual: %d\n", total, tree.Total())
+		}
+		proof, _ := tree.GenerateProof(Hash128([]byte("account 7")))
+		if !VerifySumProof(proof, tree.RootHash(), total) {
+			t.Errorf("Error: VerifySumProof: proof after update rejected")
+		}
+		if err := tree.Update(Hash128([]byte("missing")), 1); !errors.Is(err, ErrHashNotFound) {
+			t.Errorf("Error: Update: Expected: %v, Actual: %v\n", ErrHashNotFound, err)
+		}
+	})
+
+	t.Run("Lying about amounts", func(t *testing.T) {
+		proof, _ := tree.GenerateProof(Hash128([]byte("account 5")))
+		if VerifySumProof(proof, tree.RootHash(), total-1) {
+			t.Errorf("Error: VerifySumProof: wrong total accepted")
+		}
+
+		forged := *proof
+		forged.Amount++
+		if VerifySumProof(&forged, tree.RootHash(), total+1) {
+			t.Errorf("Error: VerifySumProof: inflated amount accepted")
+		}
+
+		forged = *proof
+		forged.ProofList = append([]SumProofStep{}, proof.ProofList...)
+		forged.ProofList[0].Sum = 0
+		if VerifySumProof(&forged, tree.RootHash(), total-proof.ProofList[0].Sum) {
+			t.Errorf("Error: VerifySumProof: hidden sibling sum accepted")
+		}
+	})
+
+	t.Run("Overflow and duplicates", func(t *testing.T) {
+		if _, err := tree.Insert([]byte("account 1"), 5); !errors.Is(err, ErrHashExists) {
+			t.Errorf("Error: Insert: Expected: %v, Actual: %v\n", ErrHashExists, err)
+		}
+		if _, err := tree.Insert([]byte("whale"), math.MaxUint64); err == nil {
+			t.Errorf("Error: Insert: overflowing total accepted")
+		}
+		if err := tree.Update(Hash128([]byte("account 1")), math.MaxUint64); err == nil {
+			t.Errorf("Error: Update: overflowing total accepted")
+		}
+		if tree.Total() != total {
+			t.Errorf("Error: Total: changed by a rejected call, Actual: %d\n", tree.Total())
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tiles store a MerkelLog's hashes the way the Go checksum database does
+// (golang.org/x/mod/sumdb/tlog), so they can be put on any static file
+// server. A tile of height H at level L holds up to 2^H consecutive hashes
+// from level L*H of the tree; the levels in between are recomputed from
+// them. A tile is written once it's full and never changes afterwards;
+// partial tiles carry their width in the path, so every file is immutable:
+//
+//	tile/H/L/NNN        full tile N
+//	tile/H/L/NNN.p/W    partial tile N holding W hashes
+//
+// N is written in groups of three digits, e.g. tile 1234067 is
+// x001/x234/067. Hashes are the log's 32 byte SHA-256 hashes stored back to
+// back.
+
+// DefaultTileHeight is the tile height used by the Go checksum database.
+const DefaultTileHeight = 8
+
+// tileHashSize is the size of every hash stored in a tile.
+const tileHashSize = sha256.Size
+
+// TilePath returns the path of tile index at level with the given height,
+// relative to the tile directory. width is the number of hashes in the
+// tile, 2^height for a full tile.
+func TilePath(height, level int, index uint64, width int) string {
+	digits := strconv.FormatUint(index, 10)
+	for len(digits)%3 != 0 {
+		digits = "0" + digits
+	}
+	groups := []string{}
+	for offset := 0; offset < len(digits); offset += 3 {
+		groups = append(groups, digits[offset:offset+3])
+	}
+	for group := range groups[:len(groups)-1] {
+		groups[group] = "x" + groups[group]
+	}
+
+	name := path.Join("tile", strconv.Itoa(height), strconv.Itoa(level), strings.Join(groups, "/"))
+	if width < 1<<height {
+		name += ".p/" + strconv.Itoa(width)
+	}
+	return name
+}
+
+// tileWidths calls visit for every tile a log of the given size is made of,
+// starting at tile from at every level.
+func tileWidths(height int, size uint64, from func(level int) uint64, visit func(level int, index uint64, width int) error) error {
+	for level := 0; size>>(uint(level*height)) > 0; level++ {
+		count := size >> uint(level*height)
+		for index := from(level); index<<uint(height) < count; index++ {
+			width := min(count-index<<uint(height), 1<<uint(height))
+			if err := visit(level, index, int(width)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validTileHeight(height int) error {
+	if height < 1 || height > 30 {
+		return fmt.Errorf("invalid tile height %d", height)
+	}
+	return nil
+}
+
+// TileWriter writes the tiles of a MerkelLog to a directory.
+type TileWriter struct {
+	dir    string
+	height int
+	// size is the log size of the last Write; tiles below it are done.
+	size uint64
+}
+
+// NewTileWriter creates a writer for tiles of the given height under dir.
+func NewTileWriter(dir string, height int) (*TileWriter, error) {
+	if err := validTileHeight(height); err != nil {
+		return nil, err
+	}
+	return &TileWriter{dir: dir, height: height}, nil
+}
+
+// Write writes every tile of the log that isn't on disk yet. Only tiles
+// that changed since the last Write are looked at; existing files are left
+// alone since their content can't change.
+func (writer *TileWriter) Write(merkelLog *MerkelLog) error {
+	size := merkelLog.Size()
+	from := func(level int) uint64 {
+		return writer.size >> uint(level*writer.height) >> uint(writer.height)
+	}
+
+	err := tileWidths(writer.height, size, from, func(level int, index uint64, width int) error {
+		name := filepath.Join(writer.dir, filepath.FromSlash(TilePath(writer.height, level, index, width)))
+		if _, err := os.Stat(name); err == nil {
+			return nil
+		}
+
+		hashes := merkelLog.levels[level*writer.height]
+		start := index << uint(writer.height)
+		data := make([]byte, 0, width*tileHashSize)
+		for _, hash := range hashes[start : start+uint64(width)] {
+			data = append(data, hash...)
+		}
+		return writeFileAtomic(name, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.size = size
+	return nil
+}
+
+// writeFileAtomic writes a file through a temporary file so readers never
+// see a half written tile.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), name)
+}
+
+// TileReader computes roots and proofs for a log of a given size from its
+// tiles alone. Tiles come from an untrusted file server, so proofs must
+// still be checked against a signed root as usual.
+type TileReader struct {
+	fsys   fs.FS
+	height int
+	size   uint64
+
+	mu    sync.Mutex
+	tiles map[string][]byte
+}
+
+// NewTileReader reads the tiles of a log with size entries from fsys, e.g.
+// os.DirFS of a TileWriter directory. size usually comes from a checkpoint.
+func NewTileReader(fsys fs.FS, height int, size uint64) (*TileReader, error) {
+	if err := validTileHeight(height); err != nil {
+		return nil, err
+	}
+	return &TileReader{
+		fsys:   fsys,
+		height: height,
+		size:   size,
+		tiles:  map[string][]byte{},
+	}, nil
+}
+
+// Size returns the size of the log the reader serves.
+func (reader *TileReader) Size() uint64 {
+	return reader.size
+}
+
+// RootHash returns the root hash of the log.
+func (reader *TileReader) RootHash() ([]byte, error) {
+	return rootHash(reader, reader.size)
+}
+
+// InclusionProof returns the same audit path as MerkelLog.InclusionProof.
+func (reader *TileReader) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > reader.size {
+		return nil, fmt.Errorf("size %d is beyond the log size %d", size, reader.size)
+	}
+	return inclusionProof(reader, index, size)
+}
+
+// ConsistencyProof returns the same proof as MerkelLog.ConsistencyProof.
+func (reader *TileReader) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	if second > reader.size {
+		return nil, fmt.Errorf("size %d is beyond the log size %d", second, reader.size)
+	}
+	return consistencyProof(reader, first, second)
+}
+
+// subtreeHash reads the hashes of the tile level right below the subtree
+// and hashes them back up to the subtree's level.
+func (reader *TileReader) subtreeHash(level int, index uint64) ([]byte, error) {
+	tileLevel := level / reader.height
+	span := uint64(1) << uint(level-tileLevel*reader.height)
+	start := index * span
+	if (start+span)<<uint(tileLevel*reader.height) > reader.size {
+		return nil, fmt.Errorf("no complete subtree at level %d index %d", level, index)
+	}
+
+	tileIndex := start >> uint(reader.height)
+	count := reader.size >> uint(tileLevel*reader.height)
+	width := min(count-tileIndex<<uint(reader.height), 1<<uint(reader.height))
+	tile, err := reader.readTile(tileLevel, tileIndex, int(width))
+	if err != nil {
+		return nil, err
+	}
+
+	offset := start - tileIndex<<uint(reader.height)
+	hashes := [][]byte{}
+	for position := offset; position < offset+span; position++ {
+		hashes = append(hashes, tile[position*tileHashSize:(position+1)*tileHashSize])
+	}
+	for len(hashes) > 1 {
+		parents := [][]byte{}
+		for pair := 0; pair < len(hashes); pair += 2 {
+			parents = append(parents, logNodeHash(hashes[pair], hashes[pair+1]))
+		}
+		hashes = parents
+	}
+
+	return hashes[0], nil
+}
+
+// readTile loads a tile, caching it for later proofs.
+func (reader *TileReader) readTile(level int, index uint64, width int) ([]byte, error) {
+	name := TilePath(reader.height, level, index, width)
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+
+	if tile, ok := reader.tiles[name]; ok {
+		return tile, nil
+	}
+	tile, err := fs.ReadFile(reader.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tile) != width*tileHashSize {
+		return nil, errors.New("tile " + name + " has the wrong size")
+	}
+	reader.tiles[name] = tile
+
+	return tile, nil
+}
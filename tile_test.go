@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_TilePath(t *testing.T) {
+	for _, test := range []struct {
+		level    int
+		index    uint64
+		width    int
+		expected string
+	}{
+		{0, 0, 256, "tile/8/0/000"},
+		{0, 1234067, 256, "tile/8/0/x001/x234/067"},
+		{1, 5, 3, "tile/8/1/005.p/3"},
+		{2, 1000, 256, "tile/8/2/x001/000"},
+	} {
+		if actual := TilePath(8, test.level, test.index, test.width); actual != test.expected {
+			t.Errorf("Error: TilePath: Expected: %s, Actual: %s\n", test.expected, actual)
+		}
+	}
+}
+
+func Test_Tiles(t *testing.T) {
+	t.Run("Proofs from tiles match the in-memory log", func(t *testing.T) {
+		for _, height := range []int{1, 2, 3} {
+			dir := t.TempDir()
+			writer, err := NewTileWriter(dir, height)
+			if err != nil {
+				t.Fatalf("Error: NewTileWriter: %+v\n", err)
+			}
+
+			log := NewMerkelLog()
+			for size := uint64(1); size <= 21; size++ {
+				log.Append([]byte(fmt.Sprintf("entry %d", size-1)))
+				if err := writer.Write(log); err != nil {
+					t.Fatalf("Error: Write: %+v\n", err)
+				}
+
+				reader, _ := NewTileReader(os.DirFS(dir), height, size)
+				root, err := reader.RootHash()
+				if err != nil || !bytes.Equal(root, log.RootHash()) {
+					t.Fatalf("Error: RootHash: height %d size %d: %v\n", height, size, err)
+				}
+				for index := uint64(0); index < size; index++ {
+					expected, _ := log.InclusionProof(index, size)
+					actual, err := reader.InclusionProof(index, size)
+					if err != nil || !equalHashes(expected, actual) {
+						t.Errorf("Error: InclusionProof: height %d index %d size %d: %v\n", height, index, size, err)
+					}
+				}
+				for first := uint64(0); first <= size; first++ {
+					expected, _ := log.ConsistencyProof(first, size)
+					actual, err := reader.ConsistencyProof(first, size)
+					if err != nil || !equalHashes(expected, actual) {
+						t.Errorf("Error: ConsistencyProof: height %d %d -> %d: %v\n", height, first, size, err)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("Tiles are immutable", func(t *testing.T) {
+		dir := t.TempDir()
+		writer, _ := NewTileWriter(dir, 2)
+		log, _ := testLog(6)
+		writer.Write(log)
+
+		full := filepath.Join(dir, filepath.FromSlash(TilePath(2, 0, 0, 4)))
+		partial := filepath.Join(dir, filepath.FromSlash(TilePath(2, 0, 1, 2)))
+		before, _ := os.ReadFile(full)
+		log.Append([]byte("entry 6"))
+		log.Append([]byte("entry 7"))
+		writer.Write(log)
+
+		after, _ := os.ReadFile(full)
+		if !bytes.Equal(before, after) {
+			t.Errorf("Error: Write: full tile was rewritten")
+		}
+		if _, err := os.Stat(partial); err != nil {
+			t.Errorf("Error: Write: old partial tile removed: %v\n", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(TilePath(2, 0, 1, 4)))); err != nil {
+			t.Errorf("Error: Write: completed tile missing: %v\n", err)
+		}
+	})
+
+	t.Run("Missing and corrupted tiles", func(t *testing.T) {
+		dir := t.TempDir()
+		writer, _ := NewTileWriter(dir, 2)
+		log, _ := testLog(5)
+		writer.Write(log)
+
+		reader, _ := NewTileReader(os.DirFS(dir), 2, 9)
+		if _, err := reader.RootHash(); err == nil {
+			t.Errorf("Error: RootHash: size beyond the tiles accepted")
+		}
+
+		os.WriteFile(filepath.Join(dir, filepath.FromSlash(TilePath(2, 0, 0, 4))), []byte("short"), 0o644)
+		reader, _ = NewTileReader(os.DirFS(dir), 2, 5)
+		if _, err := reader.InclusionProof(0, 5); err == nil {
+			t.Errorf("Error: InclusionProof: corrupted tile accepted")
+		}
+		if _, err := NewTileWriter(dir, 0); err == nil {
+			t.Errorf("Error: NewTileWriter: height 0 accepted")
+		}
+	})
+}
+
+func equalHashes(expected, actual [][]byte) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for index := range expected {
+		if !bytes.Equal(expected[index], actual[index]) {
+			return false
+		}
+	}
+	return true
+}
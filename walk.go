@@ -0,0 +1,123 @@
+package main
+
+import "iter"
+
+// WalkOrder selects the order in which Walk and Nodes visit the tree.
+type WalkOrder int
+
+const (
+	// PreOrder visits a node before its left and right subtrees.
+	PreOrder WalkOrder = iota
+	// PostOrder visits a node after its left and right subtrees.
+	PostOrder
+	// LevelOrder visits the tree breadth first, left to right, one depth at
+	// a time.
+	LevelOrder
+)
+
+// Visitor is called for every node reached by Walk along with the node's
+// depth, root being 0. Returning false stops the walk early.
+type Visitor func(node *Node, depth int) bool
+
+// Walk visits every node in the tree in the requested order until the
+// visitor returns false.
+func (merkelTree *MerkelTree) Walk(order WalkOrder, visit Visitor) {
+	for node, depth := range merkelTree.Nodes(order) {
+		if !visit(node, depth) {
+			return
+		}
+	}
+}
+
+// Nodes returns an iterator over every node and its depth in the requested
+// order. Nothing is collected up front so large trees can be streamed.
+//
+//	for node, depth := range tree.Nodes(PreOrder) {
+//		...
+//	}
+func (merkelTree *MerkelTree) Nodes(order WalkOrder) iter.Seq2[*Node, int] {
+	return func(yield func(*Node, int) bool) {
+		if merkelTree.root == nil {
+			return
+		}
+
+		switch order {
+		case PreOrder:
+			stack := []NodeDepth{{depth: 0, node: merkelTree.root}}
+			for len(stack) > 0 {
+				current := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if !yield(current.node, current.depth) {
+					return
+				}
+				// Right goes on the stack first so left is popped first.
+				if current.node.right != nil {
+					stack = append(stack, NodeDepth{node: current.node.right, depth: current.depth + 1})
+				}
+				if current.node.left != nil {
+					stack = append(stack, NodeDepth{node: current.node.left, depth: current.depth + 1})
+				}
+			}
+		case PostOrder:
+			var lastVisited *Node
+			stack := []NodeDepth{}
+			current := NodeDepth{depth: 0, node: merkelTree.root}
+			for len(stack) > 0 || current.node != nil {
+				if current.node != nil {
+					stack = append(stack, current)
+					current = NodeDepth{node: current.node.left, depth: current.depth + 1}
+					continue
+				}
+				top := stack[len(stack)-1]
+				// Descend right unless we've just come back up from there.
+				if top.node.right != nil && lastVisited != top.node.right {
+					current = NodeDepth{node: top.node.right, depth: top.depth + 1}
+					continue
+				}
+				if !yield(top.node, top.depth) {
+					return
+				}
+				lastVisited = top.node
+				stack = stack[:len(stack)-1]
+			}
+		case LevelOrder:
+			queue := []NodeDepth{{depth: 0, node: merkelTree.root}}
+			for len(queue) > 0 {
+				current := queue[0]
+				queue = queue[1:]
+				if !yield(current.node, current.depth) {
+					return
+				}
+				if current.node.left != nil {
+					queue = append(queue, NodeDepth{node: current.node.left, depth: current.depth + 1})
+				}
+				if current.node.right != nil {
+					queue = append(queue, NodeDepth{node: current.node.right, depth: current.depth + 1})
+				}
+			}
+		}
+	}
+}
+
+// AllLeaves returns an iterator over the leaf nodes from left to right.
+func (merkelTree *MerkelTree) AllLeaves() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := range merkelTree.Nodes(PreOrder) {
+			if node.left == nil && node.right == nil {
+				if !yield(node) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Leaves returns every leaf node from left to right.
+func (merkelTree *MerkelTree) Leaves() []*Node {
+	leaves := []*Node{}
+	for leaf := range merkelTree.AllLeaves() {
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// walkData joins the data of the visited nodes for easy comparison.
+func walkData(nodes []*Node) string {
+	data := []string{}
+	for _, node := range nodes {
+		data = append(data, string(node.data))
+	}
+	return strings.Join(data, ",")
+}
+
+func Test_Walk(t *testing.T) {
+	//   The tree
+	//               Y
+	//             /   \
+	//            X     X
+	//          /  \   /  \
+	//         X    A D    B
+	//       /  \
+	//      E    C
+	testMerkelTree := InitMerkelTree()
+	for _, data := range []string{"A", "B", "C", "D", "E"} {
+		testMerkelTree.Insert([]byte(data))
+	}
+
+	t.Run("Leaves from left to right", func(t *testing.T) {
+		if actual := walkData(testMerkelTree.Leaves()); actual != "E,C,A,D,B" {
+			t.Errorf("Error: Leaves: Expected: E,C,A,D,B, Actual: %s\n", actual)
+		}
+	})
+
+	t.Run("Walk orders", func(t *testing.T) {
+		expected := map[WalkOrder]string{
+			PreOrder:   "Y,X,X,E,C,A,X,D,B",
+			PostOrder:  "E,C,X,A,X,D,B,X,Y",
+			LevelOrder: "Y,X,X,X,A,D,B,E,C",
+		}
+		for order, want := range expected {
+			visited := []*Node{}
+			testMerkelTree.Walk(order, func(node *Node, depth int) bool {
+				visited = append(visited, node)
+				return true
+			})
+			if actual := walkData(visited); actual != want {
+				t.Errorf("Error: Walk: order %d: Expected: %s, Actual: %s\n", order, want, actual)
+			}
+		}
+	})
+
+	t.Run("Walk depths", func(t *testing.T) {
+		testMerkelTree.Walk(PreOrder, func(node *Node, depth int) bool {
+			if string(node.data) == "E" && depth != 3 {
+				t.Errorf("Error: Walk: depth of E, Expected: 3, Actual: %d\n", depth)
+			}
+			if node == testMerkelTree.root && depth != 0 {
+				t.Errorf("Error: Walk: depth of root, Expected: 0, Actual: %d\n", depth)
+			}
+			return true
+		})
+	})
+
+	t.Run("Early termination", func(t *testing.T) {
+		for _, order := range []WalkOrder{PreOrder, PostOrder, LevelOrder} {
+			visited := 0
+			testMerkelTree.Walk(order, func(node *Node, depth int) bool {
+				visited++
+				return visited < 3
+			})
+			if visited != 3 {
+				t.Errorf("Error: Walk: order %d didn't stop after 3 nodes: %d\n", order, visited)
+			}
+		}
+
+		visited := []*Node{}
+		for leaf := range testMerkelTree.AllLeaves() {
+			visited = append(visited, leaf)
+			if string(leaf.data) == "A" {
+				break
+			}
+		}
+		if actual := walkData(visited); actual != "E,C,A" {
+			t.Errorf("Error: AllLeaves: Expected: E,C,A, Actual: %s\n", actual)
+		}
+	})
+
+	t.Run("Empty tree", func(t *testing.T) {
+		emptyTree := InitMerkelTree()
+		if len(emptyTree.Leaves()) != 0 {
+			t.Errorf("Error: Leaves: empty tree returned leaves")
+		}
+		for range emptyTree.Nodes(LevelOrder) {
+			t.Errorf("Error: Nodes: empty tree yielded a node")
+		}
+	})
+}
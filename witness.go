@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUnknownLog is returned for checkpoints from a log that hasn't been
+	// added to a Witness or Monitor.
+	ErrUnknownLog = errors.New("unknown log")
+	// ErrStaleCheckpoint is returned when a log presents a checkpoint older
+	// than one it already showed.
+	ErrStaleCheckpoint = errors.New("checkpoint is older than the last one seen")
+	// ErrSplitView is returned when two checkpoints of the same log can't
+	// both be part of one append-only history.
+	ErrSplitView = errors.New("split view: log presented conflicting checkpoints")
+)
+
+// witnessedLog is what a Witness remembers about a single log.
+type witnessedLog struct {
+	verifier *NoteVerifier
+	latest   *Checkpoint
+	note     []byte
+}
+
+// Witness cosigns checkpoints of the logs it follows, but only once it has
+// checked that the new checkpoint extends the last one it cosigned. Clients
+// that require a witness cosignature can't be shown a forked log unless the
+// witness is shown the same fork.
+type Witness struct {
+	mu     sync.Mutex
+	signer *NoteSigner
+	logs   map[string]*witnessedLog
+}
+
+// NewWitness creates a witness that cosigns with signer.
+func NewWitness(signer *NoteSigner) *Witness {
+	return &Witness{
+		signer: signer,
+		logs:   map[string]*witnessedLog{},
+	}
+}
+
+// AddLog makes the witness follow the log with the given origin, whose
+// checkpoints are signed by verifier.
+func (witness *Witness) AddLog(origin string, verifier *NoteVerifier) {
+	witness.mu.Lock()
+	defer witness.mu.Unlock()
+
+	witness.logs[origin] = &witnessedLog{verifier: verifier}
+}
+
+// Latest returns the signed note of the last checkpoint the witness
+// cosigned for origin, or nil if it hasn't cosigned one yet.
+func (witness *Witness) Latest(origin string) ([]byte, error) {
+	witness.mu.Lock()
+	defer witness.mu.Unlock()
+
+	witnessed, ok := witness.logs[origin]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLog, origin)
+	}
+	return witnessed.note, nil
+}
+
+// Cosign verifies a checkpoint signed by the log and, if proof shows it's
+// consistent with the last checkpoint the witness cosigned, adds the
+// witness signature. proof is the consistency proof from the previous size
+// to the new one and is ignored for the first checkpoint of a log.
+func (witness *Witness) Cosign(note []byte, proof [][]byte) ([]byte, error) {
+	text, _, err := splitNote(note)
+	if err != nil {
+		return nil, err
+	}
+	unverified, err := ParseCheckpoint(text)
+	if err != nil {
+		return nil, err
+	}
+
+	witness.mu.Lock()
+	defer witness.mu.Unlock()
+
+	witnessed, ok := witness.logs[unverified.Origin]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLog, unverified.Origin)
+	}
+	checkpoint, _, err := OpenCheckpoint(note, witnessed.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous := witnessed.latest; previous != nil {
+		if checkpoint.Size < previous.Size {
+			return nil, fmt.Errorf("%w: size %d after size %d", ErrStaleCheckpoint, checkpoint.Size, previous.Size)
+		}
+		err := VerifyConsistency(previous.Size, checkpoint.Size, previous.Hash, checkpoint.Hash, proof)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSplitView, err)
+		}
+	}
+
+	cosigned, err := CosignNote(note, witness.signer)
+	if err != nil {
+		return nil, err
+	}
+	witnessed.latest = checkpoint
+	witnessed.note = cosigned
+
+	return cosigned, nil
+}
+
+// SplitView is the evidence of a fork: two checkpoints of the same size
+// signed by the log but with different root hashes.
+type SplitView struct {
+	Origin string
+	Size   uint64
+	First  []byte
+	Second []byte
+}
+
+// Monitor collects signed checkpoints from any source, e.g. gossip between
+// clients, and flags two roots of the same size with different hashes.
+type Monitor struct {
+	mu        sync.Mutex
+	verifiers map[string]*NoteVerifier
+	seen      map[string]map[uint64][]byte
+	forks     []SplitView
+}
+
+// NewMonitor creates a monitor that doesn't follow any log yet.
+func NewMonitor() *Monitor {
+	return &Monitor{
+		verifiers: map[string]*NoteVerifier{},
+		seen:      map[string]map[uint64][]byte{},
+	}
+}
+
+// AddLog makes the monitor accept checkpoints of origin signed by verifier.
+func (monitor *Monitor) AddLog(origin string, verifier *NoteVerifier) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	monitor.verifiers[origin] = verifier
+	if monitor.seen[origin] == nil {
+		monitor.seen[origin] = map[uint64][]byte{}
+	}
+}
+
+// Observe records a signed checkpoint. If the log signed a different root
+// for the same size before, the fork is recorded and returned along with
+// ErrSplitView; both notes are kept as proof of the log's misbehaviour.
+func (monitor *Monitor) Observe(note []byte) (*SplitView, error) {
+	text, _, err := splitNote(note)
+	if err != nil {
+		return nil, err
+	}
+	unverified, err := ParseCheckpoint(text)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	verifier, ok := monitor.verifiers[unverified.Origin]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLog, unverified.Origin)
+	}
+	checkpoint, _, err := OpenCheckpoint(note, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := monitor.seen[checkpoint.Origin]
+	previous, ok := seen[checkpoint.Size]
+	if !ok {
+		seen[checkpoint.Size] = note
+		return nil, nil
+	}
+	previousText, _, _ := splitNote(previous)
+	previousCheckpoint, _ := ParseCheckpoint(previousText)
+	if bytes.Equal(previousCheckpoint.Hash, checkpoint.Hash) {
+		return nil, nil
+	}
+
+	fork := SplitView{
+		Origin: checkpoint.Origin,
+		Size:   checkpoint.Size,
+		First:  previous,
+		Second: note,
+	}
+	monitor.forks = append(monitor.forks, fork)
+	return &fork, fmt.Errorf("%w: two roots for size %d of %s", ErrSplitView, checkpoint.Size, checkpoint.Origin)
+}
+
+// Forks returns every fork the monitor has seen so far.
+func (monitor *Monitor) Forks() []SplitView {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	return append([]SplitView{}, monitor.forks...)
+}
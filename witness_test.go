@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Witness(t *testing.T) {
+	logSigner, _ := NewNoteSigner("example.com/log", testKey(1))
+	witnessSigner, _ := NewNoteSigner("witness.example.com", testKey(2))
+	signedCheckpoint := func(log *MerkelLog) []byte {
+		note, err := SignCheckpoint(log.Checkpoint("example.com/log"), logSigner)
+		if err != nil {
+			t.Fatalf("Error: SignCheckpoint: %+v\n", err)
+		}
+		return note
+	}
+
+	witness := NewWitness(witnessSigner)
+	witness.AddLog("example.com/log", logSigner.Verifier())
+	log, _ := testLog(5)
+
+	t.Run("First checkpoint is cosigned", func(t *testing.T) {
+		cosigned, err := witness.Cosign(signedCheckpoint(log), nil)
+		if err != nil {
+			t.Fatalf("Error: Cosign: %+v\n", err)
+		}
+		if _, _, err := OpenCheckpoint(cosigned, witnessSigner.Verifier()); err != nil {
+			t.Errorf("Error: Cosign: witness signature missing: %+v\n", err)
+		}
+	})
+
+	t.Run("Consistent checkpoint is cosigned", func(t *testing.T) {
+		log.Append([]byte("entry 5"))
+		log.Append([]byte("entry 6"))
+		proof, _ := log.ConsistencyProof(5, 7)
+		if _, err := witness.Cosign(signedCheckpoint(log), proof); err != nil {
+			t.Fatalf("Error: Cosign: %+v\n", err)
+		}
+		latest, _ := witness.Latest("example.com/log")
+		checkpoint, _, _ := OpenCheckpoint(latest, witnessSigner.Verifier())
+		if checkpoint.Size != 7 {
+			t.Errorf("Error: Latest: Expected: 7, Actual: %d\n", checkpoint.Size)
+		}
+	})
+
+	t.Run("Fork is refused", func(t *testing.T) {
+		forked, _ := testLog(5)
+		forked.Append([]byte("evil 5"))
+		forked.Append([]byte("evil 6"))
+		forked.Append([]byte("evil 7"))
+		proof, _ := forked.ConsistencyProof(7, 8)
+		if _, err := witness.Cosign(signedCheckpoint(forked), proof); !errors.Is(err, ErrSplitView) {
+			t.Errorf("Error: Cosign: Expected: %v, Actual: %v\n", ErrSplitView, err)
+		}
+
+		older, _ := testLog(6)
+		if _, err := witness.Cosign(signedCheckpoint(older), nil); !errors.Is(err, ErrStaleCheckpoint) {
+			t.Errorf("Error: Cosign: Expected: %v, Actual: %v\n", ErrStaleCheckpoint, err)
+		}
+	})
+
+	t.Run("Unknown log and bad signature", func(t *testing.T) {
+		otherSigner, _ := NewNoteSigner("other.example.com", testKey(3))
+		note, _ := SignCheckpoint(log.Checkpoint("other.example.com"), otherSigner)
+		if _, err := witness.Cosign(note, nil); !errors.Is(err, ErrUnknownLog) {
+			t.Errorf("Error: Cosign: Expected: %v, Actual: %v\n", ErrUnknownLog, err)
+		}
+
+		impostor, _ := NewNoteSigner("example.com/log", testKey(4))
+		note, _ = SignCheckpoint(log.Checkpoint("example.com/log"), impostor)
+		if _, err := witness.Cosign(note, nil); err == nil {
+			t.Errorf("Error: Cosign: checkpoint signed by another key accepted")
+		}
+	})
+}
+
+func Test_Monitor(t *testing.T) {
+	logSigner, _ := NewNoteSigner("example.com/log", testKey(1))
+	monitor := NewMonitor()
+	monitor.AddLog("example.com/log", logSigner.Verifier())
+
+	honest, _ := testLog(4)
+	forked, _ := testLog(3)
+	forked.Append([]byte("evil 3"))
+
+	honestNote, _ := SignCheckpoint(honest.Checkpoint("example.com/log"), logSigner)
+	forkedNote, _ := SignCheckpoint(forked.Checkpoint("example.com/log"), logSigner)
+
+	t.Run("Same root twice is fine", func(t *testing.T) {
+		for index := 0; index < 2; index++ {
+			if fork, err := monitor.Observe(honestNote); fork != nil || err != nil {
+				t.Errorf("Error: Observe: Expected: no fork, Actual: %+v, %v\n", fork, err)
+			}
+		}
+	})
+
+	t.Run("Different root of the same size is a fork", func(t *testing.T) {
+		fork, err := monitor.Observe(forkedNote)
+		if !errors.Is(err, ErrSplitView) || fork == nil {
+			t.Fatalf("Error: Observe: Expected: %v, Actual: %v\n", ErrSplitView, err)
+		}
+		if fork.Size != 4 || string(fork.First) != string(honestNote) || string(fork.Second) != string(forkedNote) {
+			t.Errorf("Error: Observe: wrong evidence: %+v\n", fork)
+		}
+		if len(monitor.Forks()) != 1 {
+			t.Errorf("Error: Forks: Expected: 1, Actual: %d\n", len(monitor.Forks()))
+		}
+	})
+
+	t.Run("Unsigned roots are ignored", func(t *testing.T) {
+		impostor, _ := NewNoteSigner("example.com/log", testKey(4))
+		other, _ := testLog(9)
+		note, _ := SignCheckpoint(other.Checkpoint("example.com/log"), impostor)
+		if _, err := monitor.Observe(note); err == nil || errors.Is(err, ErrSplitView) {
+			t.Errorf("Error: Observe: Expected: signature error, Actual: %v\n", err)
+		}
+	})
+}